@@ -12,7 +12,6 @@ import (
 )
 
 func main() {
-	trie := shell.InitTrie()
 	// 启动时只从 HISTFILE 加载一次历史记录
 	shell.InitHistoryFile()
 
@@ -25,23 +24,48 @@ func main() {
 	//
 	// NewEx 创建一个可配置的 readline 实例
 	// Config 结构体包含各种配置选项
+	sh := shell.NewInterp()
+	completer := shell.CreateCompleter(sh)
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt:          "$ ",                        // 提示符，显示在每行输入前
-		AutoComplete:    shell.CreateCompleter(trie), // 自动补全器，当用户按下 TAB 键时调用
-		InterruptPrompt: "^C",                        // 当用户按下 Ctrl+C 时显示的提示
-		EOFPrompt:       "exit",                      // 当用户按下 Ctrl+D (EOF) 时显示的提示
+		Prompt:              "$ ",                       // 提示符，每次读取前会按 PS1/PS2 重新设置
+		AutoComplete:        completer,                  // 自动补全器，当用户按下 TAB 键时调用
+		Listener:            completer,                  // 每次按键后调用，补全需要替换整个单词时由它修改输入行
+		FuncFilterInputRune: completer.FilterInputRune,  // 每次按键前调用，补全菜单打开时由它处理方向键、TAB 和回车
+		InterruptPrompt:     "^C",                       // 当用户按下 Ctrl+C 时显示的提示
+		EOFPrompt:           "exit",                     // 当用户按下 Ctrl+D (EOF) 时显示的提示
+		Painter:             shell.NewPromptPainter(sh), // 绘制输入行，在右侧显示 RPROMPT，在下方显示补全菜单
 	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating readline: %v\n", err)
 		os.Exit(1)
 	}
-	defer rl.Close() // 关闭 readline 实例，释放资源
+	// 读取 ~/.goshellrc，其中可以定义函数、钩子和提示符
+	sh.SourceRCFile()
+	// 加载 ~/.config/goshell/completions/ 中的补全规则文件
+	sh.LoadCompletionSpecs()
+	// 在后台扫描 PATH 中的可执行文件，不阻塞第一个提示符
+	sh.ScanCommands()
 
-mainLoop:
+	// pending 保存尚未输入完整的命令（例如引号未闭合），此时使用 PS2 继续读取
+	var pending []string
 	for {
+		promptName := "PS2"
+		if len(pending) == 0 {
+			promptName = "PS1"
+			// 绘制主提示符之前执行 PROMPT_COMMAND 和 precmd
+			if sh.RunPromptHooks() {
+				break
+			}
+		}
+		// 每次读取前重新渲染提示符，使 \w、\t、\? 等转义反映最新状态
+		prefix, prompt := sh.RenderPrompt(promptName)
+		fmt.Print(prefix)
+		rl.SetPrompt(prompt)
+
 		line, err := rl.Readline()
 		if err != nil {
 			if err == readline.ErrInterrupt {
+				pending = nil
 				continue
 			}
 			// 这里就是“监听 EOF / Ctrl+D”的地方
@@ -50,139 +74,30 @@ mainLoop:
 			}
 			break
 		}
-		shell.HistoryCmdSlice = append(shell.HistoryCmdSlice, line)
-		realCommand := strings.TrimLeft(line, " ")
-		realCommand = strings.TrimRight(realCommand, " ")
-
-		// 检查是否有管道操作符
-		pipeParts := shell.ParsePipe(realCommand)
-		if len(pipeParts) > 1 {
-			// 有管道，执行管道逻辑
-			shell.HandlePipeline(pipeParts)
-			continue
-		}
+		shell.AddHistory(line)
 
-		cmdSlice := shell.ParseCommand(realCommand)
-		if len(cmdSlice) == 0 {
+		pending = append(pending, line)
+		input := strings.Join(pending, "\n")
+		if shell.IsIncomplete(input) {
 			continue
 		}
+		// 瞬态提示符：用紧凑形式重绘刚才的提示符
+		fmt.Print(sh.TransientPrompt(pending))
+		pending = nil
 
-		// 解析重定向操作符
-		actualCmdSlice, stdoutFile, stderrFile, stdappendFile, stderrappendFile := shell.ParseRedirect(cmdSlice)
-		if len(actualCmdSlice) == 0 {
-			continue
-		}
-
-		commandName := actualCmdSlice[0]
-
-		// 如果有标准输出重定向，打开文件用于写入
-		var outputFile *os.File
-		if stdoutFile != "" {
-			outputFile, err = os.Create(stdoutFile)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error creating file %s: %v\n", stdoutFile, err)
-				continue
-			}
-			defer outputFile.Close()
-		}
-
-		// 如果有标准错误重定向，打开文件用于写入错误
-		var errorFile *os.File
-		if stderrFile != "" {
-			errorFile, err = os.Create(stderrFile)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error creating file %s: %v\n", stderrFile, err)
-				continue
-			}
-			defer errorFile.Close()
-		}
-		// 如果有标准追加重定向，打开文件用于追加写入
-		var appendFile *os.File
-		if stdappendFile != "" {
-			appendFile, err = os.OpenFile(stdappendFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error opening file %s: %v\n", stdappendFile, err)
-				continue
-			}
-			defer appendFile.Close()
-		}
-		// 如果有标准错误追加重定向，打开文件用于追加写入
-		var errAppendFile *os.File
-		if stderrappendFile != "" {
-			errAppendFile, err = os.OpenFile(stderrappendFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error opening file %s: %v\n", stderrappendFile, err)
-				continue
-			}
-			defer errAppendFile.Close()
+		// 执行之前调用 preexec，参数为用户输入的整行命令
+		if strings.TrimSpace(input) != "" && sh.RunPreexec(input) {
+			break
 		}
 
-		// 对于 echo 命令，需要从原始命令中移除重定向部分
-		if commandName == "echo" {
-			// 从 realCommand 中移除重定向部分
-			echoCmd := realCommand
-			// 移除标准输出重定向
-			if stdoutFile != "" {
-				redirectPatterns := []string{" 1> " + stdoutFile, " > " + stdoutFile}
-				for _, pattern := range redirectPatterns {
-					if idx := strings.LastIndex(realCommand, pattern); idx != -1 {
-						echoCmd = strings.TrimRight(realCommand[:idx], " ")
-						break
-					}
-				}
-			}
-			// 移除标准错误重定向
-			if stderrFile != "" {
-				pattern := " 2> " + stderrFile
-				if idx := strings.LastIndex(echoCmd, pattern); idx != -1 {
-					echoCmd = strings.TrimRight(echoCmd[:idx], " ")
-				}
-			}
-			// 移除标准追加重定向
-			if stdappendFile != "" {
-				redirectPatterns := []string{" 1>> " + stdappendFile, " >> " + stdappendFile}
-				for _, pattern := range redirectPatterns {
-					if idx := strings.LastIndex(echoCmd, pattern); idx != -1 {
-						echoCmd = strings.TrimRight(echoCmd[:idx], " ")
-						break
-					}
-				}
-			}
-			// 移除标准错误追加重定向
-			if stderrappendFile != "" {
-				pattern := " 2>> " + stderrappendFile
-				if idx := strings.LastIndex(echoCmd, pattern); idx != -1 {
-					echoCmd = strings.TrimRight(echoCmd[:idx], " ")
-				}
-			}
-			if shell.HandleEcho(echoCmd, outputFile, appendFile) {
-				continue
-			}
-		}
-		switch commandName {
-		case "exit":
-			if shell.HandleExit() {
-				break mainLoop
-			}
-		case "type":
-			if shell.HandleType(actualCmdSlice, outputFile) {
-				continue
-			}
-		case "pwd":
-			if shell.HandlePwd(outputFile, errorFile, appendFile, errAppendFile) {
-				continue
-			}
-		case "cd":
-			if shell.HandleCD(actualCmdSlice, errorFile, errAppendFile) {
-				continue
-			}
-		case "history":
-			if shell.HandleHistory(actualCmdSlice, outputFile, appendFile) {
-				continue
-			}
-		default:
-			// 如果不是内置命令，尝试作为外部程序执行
-			shell.HandleExternalCommand(commandName, actualCmdSlice, outputFile, errorFile, appendFile, errAppendFile)
+		// 解析并执行命令列表（管道、&&、||、子 Shell、命令组），执行 exit 后退出循环
+		if sh.Run(input) {
+			break
 		}
 	}
+
+	// 无论是 EOF、exit 还是 set -e 退出，都先执行 EXIT trap
+	status := sh.RunExitTrap()
+	rl.Close() // 关闭 readline 实例，释放资源
+	os.Exit(status)
 }
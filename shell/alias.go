@@ -0,0 +1,142 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 处理 alias 命令
+//
+//	alias [-p]               以可重新执行的形式列出所有别名
+//	alias name=value ...     定义别名
+//	alias name ...           输出别名的定义，没有定义时状态为 1
+func runAliasBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	args := cmdSlice[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		if args[0] != "-p" {
+			fmt.Fprintf(errorWriter, "alias: %s: invalid option\n", args[0])
+			fmt.Fprintln(errorWriter, "alias: usage: alias [-p] [name[=value] ... ]")
+			return 2
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		for _, name := range sortedKeys(sh.aliases) {
+			fmt.Fprintln(writer, formatAlias(name, sh.aliases[name]))
+		}
+		return 0
+	}
+
+	status := 0
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			if value, ok := sh.aliases[name]; ok {
+				fmt.Fprintln(writer, formatAlias(name, value))
+			} else {
+				fmt.Fprintf(errorWriter, "alias: %s: not found\n", name)
+				status = 1
+			}
+			continue
+		}
+		if !isValidAliasName(name) {
+			fmt.Fprintf(errorWriter, "alias: `%s': invalid alias name\n", name)
+			status = 1
+			continue
+		}
+		sh.aliases[name] = value
+	}
+	return status
+}
+
+// 处理 unalias 命令：unalias [-a] name ...，-a 删除所有别名
+func runUnaliasBuiltin(sh *Interp, cmdSlice []string, errorWriter io.Writer) int {
+	args := cmdSlice[1:]
+	if len(args) > 0 && args[0] == "-a" {
+		clear(sh.aliases)
+		return 0
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(errorWriter, "unalias: usage: unalias [-a] name [name ...]")
+		return 2
+	}
+	status := 0
+	for _, name := range args {
+		if _, ok := sh.aliases[name]; !ok {
+			fmt.Fprintf(errorWriter, "unalias: %s: not found\n", name)
+			status = 1
+			continue
+		}
+		delete(sh.aliases, name)
+	}
+	return status
+}
+
+// formatAlias 以 alias 命令的形式输出别名定义，例如 alias ll='ls -l'
+func formatAlias(name string, value string) string {
+	return "alias " + name + "='" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// isValidAliasName 判断别名是否合法：不能为空，不能包含引号、空白、= 和控制操作符
+func isValidAliasName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n'\"\\`$=/;|&()<>")
+}
+
+// expandAliases 展开输入中位于命令位置的别名：行首、控制操作符和 { 之后以及变量赋值之后的第一个单词，
+// 单词不能带引号或转义。别名的值按输入重新分析，其中命令位置的别名也会展开，但正在展开的别名不会再次展开
+// （例如 alias ls='ls -F'）；值以空格结尾时，之后的单词也检查是否是别名
+func (sh *Interp) expandAliases(line string) string {
+	if len(sh.aliases) == 0 {
+		return line
+	}
+	return sh.expandAliasesIn(line, make(map[string]bool))
+}
+
+func (sh *Interp) expandAliasesIn(line string, expanding map[string]bool) string {
+	var result strings.Builder
+	last := 0
+	commandPosition := true
+	redirectTarget := false
+	for _, tok := range lexLine(line) {
+		switch tok.kind {
+		case tokenOperator:
+			commandPosition = tok.text != ")"
+			continue
+		case tokenRedirect:
+			redirectTarget = true
+			continue
+		}
+		if redirectTarget {
+			// 重定向的目标文件不是命令名
+			redirectTarget = false
+			continue
+		}
+		if !commandPosition {
+			continue
+		}
+		if tok.text == "{" || isAssignment(tok.text) {
+			continue
+		}
+		commandPosition = false
+		value, ok := sh.aliases[tok.text]
+		if !ok || expanding[tok.text] {
+			continue
+		}
+		expanding[tok.text] = true
+		result.WriteString(line[last:tok.start])
+		result.WriteString(sh.expandAliasesIn(value, expanding))
+		delete(expanding, tok.text)
+		last = tok.end
+		commandPosition = strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\t")
+	}
+	if last == 0 {
+		return line
+	}
+	result.WriteString(line[last:])
+	return result.String()
+}
@@ -0,0 +1,50 @@
+package shell
+
+import "testing"
+
+// 别名只在命令位置展开，值中的别名继续展开，但不会递归展开自身
+func TestExpandAliases(t *testing.T) {
+	sh := NewInterp()
+	sh.aliases["ll"] = "ls -l"
+	sh.aliases["ls"] = "ls -F"
+	sh.aliases["g"] = "git"
+	sh.aliases["sudo"] = "sudo "
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"ll /tmp", "ls -F -l /tmp"},
+		{"echo ll; ll", "echo ll; ls -F -l"},
+		{"cat x | g status && { g log; }", "cat x | git status && { git log; }"},
+		{"FOO=1 g diff > ll", "FOO=1 git diff > ll"},
+		{"'ll' \\g", "'ll' \\g"},
+		{"sudo g push", "sudo  git push"},
+	}
+	for _, tt := range tests {
+		if got := sh.expandAliases(tt.line); got != tt.want {
+			t.Errorf("expandAliases(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+// type、command -v 和 alias 输出别名的定义，别名排在查找顺序的最前面
+func TestAliasLookup(t *testing.T) {
+	sh := NewInterp()
+	runTest(t, sh, "alias dirs='echo here' q=\"it's\"")
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"dirs", "here\n"},
+		{"type dirs", "dirs is aliased to `echo here'\n"},
+		{"type -t dirs; type -at dirs", "alias\nalias\nbuiltin\n"},
+		{"command -v dirs", "alias dirs='echo here'\n"},
+		{"alias", "alias dirs='echo here'\nalias q='it'\\''s'\n"},
+		{"unalias dirs; type -t dirs", "builtin\n"},
+	}
+	for _, tt := range tests {
+		if got := runTest(t, sh, sh.expandAliases(tt.script)); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
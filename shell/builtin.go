@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"io"
 	"strings"
-
-	"go_shell/utils"
 )
 
 func isBuiltinCommand(name string) bool {
@@ -16,20 +14,72 @@ func isBuiltinCommand(name string) bool {
 	}
 	return false
 }
-func runBuiltinCommand(cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+
+// runBuiltinCommand 执行内置命令，返回退出状态
+func runBuiltinCommand(sh *Interp, cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(cmd.args) == 0 {
+		return 0
+	}
 	switch cmd.args[0] {
 	case "echo":
 		runEchoBuiltin(cmd.raw, stdout)
+		return 0
 	case "type":
-		runTypeBuiltin(cmd.args, stdout)
+		return runTypeBuiltin(sh, cmd.args, stdout, stderr)
+	case "exit":
+		return runExitBuiltin(sh, cmd.args, stderr)
+	case "pwd":
+		return runPwdBuiltin(sh, cmd.args, stdout, stderr)
+	case "cd":
+		return runCDBuiltin(sh, cmd.args, stdout, stderr)
+	case "history":
+		return runHistoryBuiltin(sh, cmd.args, stdout, stderr)
+	case "pushd":
+		return runPushdBuiltin(sh, cmd.args, stdout, stderr)
+	case "popd":
+		return runPopdBuiltin(sh, cmd.args, stdout, stderr)
+	case "dirs":
+		return runDirsBuiltin(sh, cmd.args, stdout, stderr)
+	case "source", ".":
+		return runSourceBuiltin(sh, cmd.args, stdin, stdout, stderr)
+	case "return":
+		return runReturnBuiltin(sh, cmd.args, stderr)
+	case "set":
+		return runSetBuiltin(sh, cmd.args, stdout, stderr)
+	case "shopt":
+		return runShoptBuiltin(sh, cmd.args, stdout, stderr)
+	case "trap":
+		return runTrapBuiltin(sh, cmd.args, stdout, stderr)
+	case "exec":
+		return runExecBuiltin(sh, cmd, stdin, stdout, stderr)
+	case "command":
+		return runCommandBuiltin(sh, cmd, stdin, stdout, stderr)
+	case "builtin":
+		return runBuiltinBuiltin(sh, cmd, stdin, stdout, stderr)
+	case "hash":
+		return runHashBuiltin(sh, cmd.args, stdout, stderr)
+	case "complete":
+		return runCompleteBuiltin(sh, cmd.args, stdout, stderr)
+	case "compgen":
+		return runCompgenBuiltin(sh, cmd.args, stdout, stderr)
+	case "export":
+		return runExportBuiltin(sh, cmd.args, stdout, stderr)
+	case "alias":
+		return runAliasBuiltin(sh, cmd.args, stdout, stderr)
+	case "unalias":
+		return runUnaliasBuiltin(sh, cmd.args, stderr)
 	default:
-		fmt.Fprintf(stderr, "%s: unsupported pipeline builtin\n", cmd.args[0])
+		fmt.Fprintf(stderr, "%s: unsupported builtin\n", cmd.args[0])
+		return 1
 	}
-	return nil
 }
 
 func runEchoBuiltin(realCommand string, writer io.Writer) {
 	echoPrefix := "echo "
+	if realCommand == "echo" {
+		fmt.Fprintln(writer)
+		return
+	}
 	if !strings.HasPrefix(realCommand, echoPrefix) {
 		return
 	}
@@ -129,25 +179,3 @@ func runEchoBuiltin(realCommand string, writer io.Writer) {
 		fmt.Fprintln(writer)
 	}
 }
-func runTypeBuiltin(cmdSlice []string, writer io.Writer) bool {
-	if len(cmdSlice) != 2 {
-		return false
-	}
-	testedType := cmdSlice[1]
-	isBuiltin := false
-	for _, shell := range ShellSlice {
-		if testedType == shell {
-			isBuiltin = true
-			fmt.Fprintf(writer, "%s is a shell builtin\n", shell)
-			break
-		}
-	}
-	if !isBuiltin {
-		if fullPath, found := utils.FindExecutable(testedType); found {
-			fmt.Fprintf(writer, "%s is %s\n", testedType, fullPath)
-		} else {
-			fmt.Fprintf(writer, "%s: not found\n", testedType)
-		}
-	}
-	return true
-}
@@ -0,0 +1,251 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go_shell/utils"
+)
+
+// 同时扫描 PATH 目录的 goroutine 数量上限
+const scanWorkers = 8
+
+// commandIndex 维护补全和命令建议使用的命令名 Trie，与 PATH 保持同步：
+// PATH 中的相对目录（如 "." 或 "bin"）与查找命令时一样相对于 Shell 的工作目录解析，
+// 解析后的目录列表改变（PATH 改变，或 PATH 含有相对目录时切换了工作目录）或执行 hash -r 时在后台重新扫描所有目录，多个目录由有限数量的 goroutine 同时扫描，
+// 扫描期间补全使用已经扫描完的部分；
+// PATH 中某个目录的修改时间变化（安装或删除了程序）时只重新扫描这个目录，更新受影响的命令名
+// Trie 中每个命令名记录类别（builtin 或 command）、完整路径和执行次数，重新扫描时保留执行次数
+// 子 Shell 与父 Shell 共用同一个索引
+type commandIndex struct {
+	mu     sync.Mutex
+	trie   *utils.Trie
+	key    string                     // 建立 Trie 时 PATH 解析后的目录列表
+	dirs   []string                   // PATH 中的目录（已解析为绝对路径），按查找顺序排列并去重
+	names  map[string]map[string]bool // 已经扫描完的目录中的可执行文件
+	mtimes map[string]time.Time       // 扫描时各目录的修改时间，不存在的目录记录为零值
+	stale  bool                       // hash -r 要求重新扫描
+	scan   *pathScan                  // 正在进行的后台扫描，没有时为 nil
+}
+
+// pathScan 是一次后台扫描，PATH 在扫描期间再次改变时旧的扫描结果被丢弃
+type pathScan struct {
+	old  *utils.Trie   // 扫描之前的 Trie，用于保留执行次数
+	done chan struct{} // 所有目录扫描完后关闭
+}
+
+// lookup 返回与 pathEnv 和工作目录 workDir 对应的 Trie，需要时开始后台扫描；
+// 后台扫描期间返回的 Trie 只包含已经扫描完的目录
+func (idx *commandIndex) lookup(pathEnv string, workDir string) *utils.Trie {
+	dirs := utils.PathDirs(pathEnv, workDir)
+	key := strings.Join(dirs, "\x00")
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.trie == nil || idx.stale || key != idx.key {
+		idx.rebuild(key, dirs)
+	} else if idx.scan == nil {
+		if changed := idx.changedDirs(); len(changed) > 0 {
+			idx.refresh(changed)
+		}
+	}
+	return idx.trie
+}
+
+// wait 等待正在进行的后台扫描结束
+func (idx *commandIndex) wait() {
+	idx.mu.Lock()
+	scan := idx.scan
+	idx.mu.Unlock()
+	if scan != nil {
+		<-scan.done
+	}
+}
+
+// invalidate 使下一次 lookup 重新扫描 PATH
+func (idx *commandIndex) invalidate() {
+	idx.mu.Lock()
+	idx.stale = true
+	idx.mu.Unlock()
+}
+
+// recordUse 把命令的执行次数加一，补全时常用的命令排在前面
+// 还没有建立 Trie 或命令不在 Trie 中（例如函数）时不记录
+func (idx *commandIndex) recordUse(name string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.trie != nil {
+		idx.trie.IncrementUses(name)
+	}
+}
+
+// rebuild 创建新的 Trie 并立即插入内置命令（来自 ShellSlice），然后在后台扫描 dirs 中的各个目录
+func (idx *commandIndex) rebuild(key string, dirs []string) {
+	scan := &pathScan{old: idx.trie, done: make(chan struct{})}
+	idx.trie = utils.Constructor()
+	idx.key = key
+	idx.stale = false
+	idx.dirs = nil
+	idx.names = make(map[string]map[string]bool)
+	idx.mtimes = make(map[string]time.Time)
+	idx.scan = scan
+
+	// 插入内置命令
+	for _, name := range ShellSlice {
+		idx.trie.InsertEntry(utils.Entry{Word: name, Kind: "builtin", Uses: previousUses(scan.old, name)})
+	}
+
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		if !seen[dir] {
+			seen[dir] = true
+			idx.dirs = append(idx.dirs, dir)
+		}
+	}
+	go idx.runScan(scan, idx.dirs)
+}
+
+// runScan 用最多 scanWorkers 个 goroutine 扫描目录，每扫描完一个目录就把结果合并到 Trie
+func (idx *commandIndex) runScan(scan *pathScan, dirs []string) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < min(scanWorkers, len(dirs)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range jobs {
+				// 先记录修改时间再读取目录，扫描期间发生的变化在之后的 lookup 中也能发现
+				mtime := dirModTime(dir)
+				names := executablesIn(dir)
+				idx.merge(scan, dir, mtime, names)
+			}
+		}()
+	}
+	for _, dir := range dirs {
+		jobs <- dir
+	}
+	close(jobs)
+	wg.Wait()
+
+	idx.mu.Lock()
+	if idx.scan == scan {
+		idx.scan = nil
+	}
+	idx.mu.Unlock()
+	close(scan.done)
+}
+
+// merge 把一个目录的扫描结果合并到 Trie：命令名指向已扫描的目录中按 PATH 顺序最先包含它的那个，
+// 因此结果与各目录扫描完成的先后无关；扫描已经被新的扫描取代时丢弃结果
+func (idx *commandIndex) merge(scan *pathScan, dir string, mtime time.Time, names map[string]bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.scan != scan {
+		return
+	}
+	idx.mtimes[dir] = mtime
+	idx.names[dir] = names
+	for name := range names {
+		if isBuiltinCommand(name) || idx.owner(name) != dir {
+			continue
+		}
+		entry, ok := idx.trie.Get(name)
+		if !ok {
+			entry.Uses = previousUses(scan.old, name)
+		}
+		entry.Word, entry.Kind, entry.Source = name, "command", filepath.Join(dir, name)
+		idx.trie.InsertEntry(entry)
+	}
+}
+
+// owner 返回已扫描的目录中按 PATH 顺序第一个包含 name 的目录，没有时返回空字符串
+func (idx *commandIndex) owner(name string) string {
+	for _, dir := range idx.dirs {
+		if idx.names[dir][name] {
+			return dir
+		}
+	}
+	return ""
+}
+
+// previousUses 返回命令名在重新扫描之前的执行次数
+func previousUses(old *utils.Trie, name string) int {
+	if old == nil {
+		return 0
+	}
+	entry, _ := old.Get(name)
+	return entry.Uses
+}
+
+// changedDirs 返回 PATH 中修改时间与扫描时不同的目录
+func (idx *commandIndex) changedDirs() []string {
+	var changed []string
+	for _, dir := range idx.dirs {
+		if !dirModTime(dir).Equal(idx.mtimes[dir]) {
+			changed = append(changed, dir)
+		}
+	}
+	return changed
+}
+
+// refresh 重新扫描修改过的目录，只更新增加或删除的命令名：
+// 命令名改为指向 PATH 中仍然包含它的第一个目录，所有目录中都没有时从 Trie 中删除
+func (idx *commandIndex) refresh(dirs []string) {
+	affected := make(map[string]bool)
+	for _, dir := range dirs {
+		idx.mtimes[dir] = dirModTime(dir)
+		names := executablesIn(dir)
+		for name := range names {
+			if !idx.names[dir][name] {
+				affected[name] = true
+			}
+		}
+		for name := range idx.names[dir] {
+			if !names[name] {
+				affected[name] = true
+			}
+		}
+		idx.names[dir] = names
+	}
+	for name := range affected {
+		if isBuiltinCommand(name) {
+			continue
+		}
+		owner := idx.owner(name)
+		if owner == "" {
+			idx.trie.Delete(name)
+			continue
+		}
+		entry, _ := idx.trie.Get(name)
+		entry.Word, entry.Kind, entry.Source = name, "command", filepath.Join(owner, name)
+		idx.trie.InsertEntry(entry)
+	}
+}
+
+// dirModTime 返回目录的修改时间，目录不存在时返回零值
+func dirModTime(dir string) time.Time {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// commandTrie 返回内置命令和当前 PATH 中可执行文件的 Trie，后台扫描期间只包含已经扫描完的目录
+func (sh *Interp) commandTrie() *utils.Trie {
+	return sh.commands.lookup(sh.getVar("PATH"), sh.dir)
+}
+
+// completeCommandTrie 与 commandTrie 相同，但等待后台扫描结束，用于需要完整结果的 compgen 和命令建议
+func (sh *Interp) completeCommandTrie() *utils.Trie {
+	trie := sh.commandTrie()
+	sh.commands.wait()
+	return trie
+}
+
+// ScanCommands 在后台开始扫描 PATH，启动时调用，第一次补全时不需要等待扫描
+func (sh *Interp) ScanCommands() {
+	sh.commandTrie()
+}
@@ -0,0 +1,387 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// completionSpec 是 complete 为命令注册的补全规则，也用于 compgen 生成候选项
+type completionSpec struct {
+	actions  []string        // 生成候选项的动作，例如 file、directory、command
+	wordList string          // -W 的单词列表，补全时才展开变量
+	function string          // -F 的函数名，函数把候选项放在 COMPREPLY 数组中
+	options  map[string]bool // -o 的选项：filenames、nospace、default、dirnames
+}
+
+// completionActions 是 -A 支持的动作，以及对应的单字母选项
+var completionActions = []struct {
+	name string
+	flag byte
+}{
+	{"builtin", 'b'},
+	{"command", 'c'},
+	{"directory", 'd'},
+	{"file", 'f'},
+	{"function", 0},
+	{"variable", 'v'},
+}
+
+// completionOptions 是 -o 支持的选项
+var completionOptions = []string{"default", "dirnames", "filenames", "nospace"}
+
+func completionActionByFlag(flag byte) (string, bool) {
+	for _, action := range completionActions {
+		if action.flag != 0 && action.flag == flag {
+			return action.name, true
+		}
+	}
+	return "", false
+}
+
+func isCompletionAction(name string) bool {
+	for _, action := range completionActions {
+		if action.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isCompletionOption(name string) bool {
+	for _, option := range completionOptions {
+		if option == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCompletionSpec 解析 complete 和 compgen 共用的选项，extra 中的单字母选项由调用者处理
+// 返回剩余的参数
+func parseCompletionSpec(name string, args []string, extra string, flags map[byte]string, errorWriter io.Writer) (*completionSpec, []string, bool) {
+	spec := &completionSpec{options: make(map[string]bool)}
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		arg := args[0]
+		args = args[1:]
+		for i := 1; i < len(arg); i++ {
+			flag := arg[i]
+			if action, ok := completionActionByFlag(flag); ok {
+				spec.actions = append(spec.actions, action)
+				continue
+			}
+			if strings.IndexByte(extra, flag) != -1 {
+				flags[flag] = ""
+				continue
+			}
+			if strings.IndexByte("WFoAV", flag) == -1 {
+				fmt.Fprintf(errorWriter, "%s: -%c: invalid option\n", name, flag)
+				return nil, nil, false
+			}
+			// 带参数的选项：参数可以紧跟在选项之后，也可以是下一个单词
+			value := arg[i+1:]
+			if value == "" {
+				if len(args) == 0 {
+					fmt.Fprintf(errorWriter, "%s: -%c: option requires an argument\n", name, flag)
+					return nil, nil, false
+				}
+				value = args[0]
+				args = args[1:]
+			}
+			switch flag {
+			case 'W':
+				spec.wordList = value
+			case 'F':
+				spec.function = value
+			case 'V':
+				flags['V'] = value
+			case 'o':
+				if !isCompletionOption(value) {
+					fmt.Fprintf(errorWriter, "%s: %s: invalid option name\n", name, value)
+					return nil, nil, false
+				}
+				spec.options[value] = true
+			case 'A':
+				if !isCompletionAction(value) {
+					fmt.Fprintf(errorWriter, "%s: %s: invalid action name\n", name, value)
+					return nil, nil, false
+				}
+				spec.actions = append(spec.actions, value)
+			}
+			break
+		}
+	}
+	return spec, args, true
+}
+
+// 处理 complete 命令
+//
+//	complete [-bcdfv] [-o option] [-A action] [-W wordlist] [-F function] name ...
+//	complete -p [name ...]  以可重新执行的形式输出补全规则
+//	complete -r [name ...]  删除补全规则，没有指定命令时全部删除
+func runCompleteBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	flags := make(map[byte]string)
+	spec, names, ok := parseCompletionSpec("complete", cmdSlice[1:], "pr", flags, errorWriter)
+	if !ok {
+		fmt.Fprintln(errorWriter, "complete: usage: complete [-bcdfv] [-pr] [-o option] [-A action] [-W wordlist] [-F function] [name ...]")
+		return 2
+	}
+	_, remove := flags['r']
+	_, print := flags['p']
+	defined := len(spec.actions) > 0 || spec.wordList != "" || spec.function != "" || len(spec.options) > 0
+
+	switch {
+	case remove:
+		if len(names) == 0 {
+			clear(sh.completions)
+		}
+		for _, name := range names {
+			delete(sh.completions, name)
+		}
+		return 0
+	case print || (!defined && len(names) == 0):
+		if len(names) == 0 {
+			for name := range sh.completions {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+		status := 0
+		for _, name := range names {
+			registered, ok := sh.completions[name]
+			if !ok {
+				fmt.Fprintf(errorWriter, "complete: %s: no completion specification\n", name)
+				status = 1
+				continue
+			}
+			fmt.Fprintln(writer, registered.String(name))
+		}
+		return status
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(errorWriter, "complete: usage: complete [-bcdfv] [-pr] [-o option] [-A action] [-W wordlist] [-F function] [name ...]")
+		return 2
+	}
+	for _, name := range names {
+		sh.completions[name] = spec
+	}
+	return 0
+}
+
+// String 以 complete 命令的形式输出补全规则
+func (spec *completionSpec) String(name string) string {
+	words := []string{"complete"}
+	for _, option := range completionOptions {
+		if spec.options[option] {
+			words = append(words, "-o", option)
+		}
+	}
+	for _, action := range spec.actions {
+		words = append(words, "-A", action)
+	}
+	if spec.wordList != "" {
+		words = append(words, "-W", shellQuote(spec.wordList))
+	}
+	if spec.function != "" {
+		words = append(words, "-F", spec.function)
+	}
+	return strings.Join(append(words, shellQuote(name)), " ")
+}
+
+// 处理 compgen 命令：按照与 complete 相同的选项生成与 word 匹配的候选项，每行输出一个
+// -V array 把候选项保存在数组变量中而不是输出，补全函数可以用它设置 COMPREPLY
+func runCompgenBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	flags := make(map[byte]string)
+	spec, args, ok := parseCompletionSpec("compgen", cmdSlice[1:], "", flags, errorWriter)
+	if !ok {
+		fmt.Fprintln(errorWriter, "compgen: usage: compgen [-bcdfv] [-o option] [-A action] [-W wordlist] [-F function] [-V varname] [word]")
+		return 2
+	}
+	word := ""
+	if len(args) > 0 {
+		word = args[0]
+	}
+	if slices.Contains(spec.actions, "command") {
+		// compgen 的输出需要完整的命令列表，等待后台扫描 PATH 结束
+		sh.completeCommandTrie()
+	}
+	context := completionContext{words: []string{"compgen", word}, cword: 1}
+	candidates := sh.specCandidates(spec, context, word, word, len(word))
+	results := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		results[i] = candidate.text
+	}
+	if name, ok := flags['V']; ok {
+		sh.setArray(name, results)
+	} else {
+		for _, result := range results {
+			fmt.Fprintln(writer, result)
+		}
+	}
+	if len(results) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// specCandidates 按照补全规则生成与 word 匹配的候选项
+// 动作和 -W 的结果按前缀过滤；-F 的函数通过 COMP_WORDS、COMP_CWORD、COMP_LINE、COMP_POINT
+// 获得命令行，参数为命令名、当前单词和前一个单词，COMPREPLY 中的结果原样使用
+func (sh *Interp) specCandidates(spec *completionSpec, context completionContext, word string, line string, pos int) []completionCandidate {
+	var candidates []completionCandidate
+	add := func(text string) {
+		candidates = append(candidates, completionCandidate{text: text, display: text, suffix: " "})
+	}
+
+	for _, action := range spec.actions {
+		switch action {
+		case "file":
+			candidates = append(candidates, sh.fileCandidates(word, completeFiles)...)
+		case "directory":
+			candidates = append(candidates, sh.fileCandidates(word, completeDirs)...)
+		case "builtin":
+			for _, name := range ShellSlice {
+				if strings.HasPrefix(name, word) {
+					add(name)
+				}
+			}
+		case "command":
+			for _, name := range sh.commandTrie().FindCompletions(word, 0) {
+				add(name)
+			}
+		case "function":
+			for _, name := range sortedKeys(sh.funcs) {
+				if strings.HasPrefix(name, word) {
+					add(name)
+				}
+			}
+		case "variable":
+			for _, name := range sh.variableNames(word) {
+				add(name)
+			}
+		}
+	}
+
+	if spec.wordList != "" {
+		words, _ := sh.expandWords(sh.expandVariables(spec.wordList))
+		for _, w := range words {
+			if strings.HasPrefix(w, word) {
+				add(w)
+			}
+		}
+	}
+
+	if body, ok := sh.funcs[spec.function]; ok {
+		previous := ""
+		if context.cword > 0 {
+			previous = context.words[context.cword-1]
+		}
+		// 这些变量只在函数执行期间存在，结束后恢复原来的值，不会留在用户的 Shell 中
+		saved := sh.saveVars(compVars)
+		sh.setArray("COMP_WORDS", context.words)
+		sh.setVar("COMP_CWORD", strconv.Itoa(context.cword))
+		sh.setVar("COMP_LINE", line)
+		sh.setVar("COMP_POINT", strconv.Itoa(pos))
+		sh.setArray("COMPREPLY", nil)
+		status := sh.status
+		stdin, stdout, stderr := sh.stdio()
+		sh.callFunction(body, []string{spec.function, context.words[0], word, previous}, stdin, stdout, stderr)
+		sh.status = status
+		for _, reply := range sh.getArray("COMPREPLY") {
+			add(reply)
+		}
+		sh.restoreVars(saved)
+	}
+
+	// -o filenames 把结果当作文件名处理，目录追加 /；-o nospace 不追加空格
+	for i := range candidates {
+		if spec.options["filenames"] && candidates[i].suffix == " " {
+			if info, err := os.Stat(sh.resolvePath(sh.expandTilde(candidates[i].text))); err == nil && info.IsDir() {
+				candidates[i].suffix = "/"
+			}
+		}
+		if spec.options["nospace"] && candidates[i].suffix == " " {
+			candidates[i].suffix = ""
+		}
+	}
+
+	// 没有候选项时，-o default 使用文件名补全，-o dirnames 使用目录补全
+	if len(candidates) == 0 && spec.options["default"] {
+		return sh.fileCandidates(word, completeFiles)
+	}
+	if len(candidates) == 0 && spec.options["dirnames"] {
+		return sh.fileCandidates(word, completeDirs)
+	}
+	return candidates
+}
+
+// compVars 是调用 -F 指定的函数时设置的变量
+var compVars = []string{"COMP_WORDS", "COMP_CWORD", "COMP_LINE", "COMP_POINT", "COMPREPLY"}
+
+// savedVar 是变量在临时设置之前的状态
+type savedVar struct {
+	name     string
+	value    string
+	elements []string
+	set      bool
+	array    bool
+	exported bool
+}
+
+// saveVars 保存变量当前的值，之后用 restoreVars 恢复
+func (sh *Interp) saveVars(names []string) []savedVar {
+	saved := make([]savedVar, len(names))
+	for i, name := range names {
+		saved[i].name = name
+		saved[i].value, saved[i].set = sh.vars[name]
+		saved[i].elements, saved[i].array = sh.arrays[name]
+		saved[i].exported = sh.exported[name]
+	}
+	return saved
+}
+
+// restoreVars 恢复 saveVars 保存的变量，原来没有设置的变量被删除
+func (sh *Interp) restoreVars(saved []savedVar) {
+	for _, v := range saved {
+		delete(sh.vars, v.name)
+		delete(sh.arrays, v.name)
+		delete(sh.exported, v.name)
+		if v.set {
+			sh.vars[v.name] = v.value
+		}
+		if v.array {
+			sh.arrays[v.name] = v.elements
+		}
+		if v.exported {
+			sh.exported[v.name] = true
+		}
+	}
+}
+
+// variableNames 按名称排序返回以 prefix 开头的变量名
+func (sh *Interp) variableNames(prefix string) []string {
+	var names []string
+	for _, name := range sortedKeys(sh.vars) {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,239 @@
+package shell
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/chzyer/readline/runes"
+)
+
+// 候选项超过这个数量时先询问是否全部显示，可以用 COMPLETION_QUERY_ITEMS 修改
+const defaultQueryItems = 100
+
+// completionMenu 是无法继续补全时显示在输入行下方的候选项菜单
+// 菜单打开期间 FilterInputRune 拦截按键：TAB 和方向键选择候选项，回车接受，其他按键关闭菜单后照常处理
+type completionMenu struct {
+	candidates []completionCandidate
+	selected   int  // 选中的候选项，-1 表示还没有选择
+	asking     bool // 正在询问 Display all N possibilities?
+	top        int  // 分页显示时第一行可见的行
+
+	line string         // 打开菜单时的输入行，接受候选项时替换其中的单词
+	pos  int            // 光标位置（按 rune 计算）
+	word completionWord // 正在补全的单词
+}
+
+// openMenu 打开候选项菜单，候选项较多时先询问是否全部显示
+// 菜单由 PromptPainter 绘制，这里保存一个不改变内容的整行修改，使 readline 重绘输入行
+func (c *CustomCompleter) openMenu(line string, pos int, word completionWord, candidates []completionCandidate) ([][]rune, int) {
+	limit := defaultQueryItems
+	if n, err := strconv.Atoi(c.sh.getVar("COMPLETION_QUERY_ITEMS")); err == nil {
+		limit = n
+	}
+	c.sh.completionMenu = &completionMenu{
+		candidates: candidates,
+		selected:   -1,
+		asking:     limit > 0 && len(candidates) > limit,
+		line:       line,
+		pos:        pos,
+		word:       word,
+	}
+	c.editLine, c.editPos = []rune(line), pos
+	return nil, 0
+}
+
+// FilterInputRune 用作 readline 的 FuncFilterInputRune，在菜单打开期间处理按键
+// 返回 false 表示按键已被处理，readline 只重绘输入行（菜单随之重绘）
+func (c *CustomCompleter) FilterInputRune(r rune) (rune, bool) {
+	menu := c.sh.completionMenu
+	if menu == nil {
+		return r, true
+	}
+	if menu.asking {
+		// y 或空格显示全部候选项，其他按键取消
+		if r == 'y' || r == 'Y' || r == ' ' {
+			menu.asking = false
+		} else {
+			c.closeMenu()
+		}
+		return r, false
+	}
+
+	width, _ := menuSize()
+	columns := menu.columns(width)
+	n := len(menu.candidates)
+	switch r {
+	case readline.CharTab, readline.CharForward:
+		menu.selected = (menu.selected + 1) % n
+	case readline.CharBackward:
+		menu.selected = (menu.selected - 1 + n) % n
+	case readline.CharNext:
+		switch {
+		case menu.selected == -1:
+			menu.selected = 0
+		case menu.selected+columns < n:
+			menu.selected += columns
+		default:
+			// 到底后回到下一列的第一行
+			menu.selected = (menu.selected%columns + 1) % min(columns, n)
+		}
+	case readline.CharPrev:
+		switch {
+		case menu.selected == -1:
+			menu.selected = n - 1
+		case menu.selected-columns >= 0:
+			menu.selected -= columns
+		default:
+			// 到顶后回到上一列的最后一行
+			column := (menu.selected%columns - 1 + columns) % columns
+			last := column + (n-1-column)/columns*columns
+			menu.selected = max(last, 0)
+		}
+	case readline.CharEnter, readline.CharCtrlJ:
+		if menu.selected == -1 {
+			// 没有选择时回车照常执行命令
+			c.closeMenu()
+			return r, true
+		}
+		// 接受选中的候选项：替换单词后由 OnChange 设置输入行，CharBell 只让 readline 调用 Listener
+		candidate := menu.candidates[menu.selected]
+		c.closeMenu()
+		c.replaceWord(menu.line, menu.pos, menu.word, candidate.text, candidate.closing(menu.word))
+		return readline.CharBell, true
+	case readline.CharBell, readline.CharInterrupt:
+		c.closeMenu()
+		return r, r == readline.CharInterrupt
+	default:
+		c.closeMenu()
+		return r, true
+	}
+	return r, false
+}
+
+// closeMenu 关闭菜单，readline 下一次重绘时菜单被清除
+func (c *CustomCompleter) closeMenu() {
+	c.sh.completionMenu = nil
+	c.tabPressed = false
+}
+
+// menuSize 返回终端的宽度和高度，无法获取时使用 80x24
+func menuSize() (int, int) {
+	width, height, err := readline.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// hasDescriptions 判断是否有候选项带说明，带说明时每行显示一个候选项
+func (m *completionMenu) hasDescriptions() bool {
+	for _, candidate := range m.candidates {
+		if candidate.description != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// columns 返回菜单的列数，候选项按行排列
+func (m *completionMenu) columns(width int) int {
+	if m.hasDescriptions() {
+		return 1
+	}
+	return max(1, (width-1)/m.cellWidth())
+}
+
+// cellWidth 是每一列的宽度：最长的候选项加两个空格
+func (m *completionMenu) cellWidth() int {
+	cell := 0
+	for _, candidate := range m.candidates {
+		cell = max(cell, displayWidth(candidate.display))
+	}
+	return cell + 2
+}
+
+// render 返回输入行下方菜单的内容（不含第一个换行）和占用的行数
+// 行数超过终端高度时只显示包含选中项的一页，并在最后一行显示位置
+func (m *completionMenu) render(width int, height int, inputRows int) (string, int) {
+	if m.asking {
+		return "Display all " + strconv.Itoa(len(m.candidates)) + " possibilities? (y or n)", 1
+	}
+	columns := m.columns(width)
+	rows := (len(m.candidates) + columns - 1) / columns
+	visible := max(1, min(rows, height-inputRows-1))
+	if visible < rows {
+		// 留一行显示位置
+		visible = max(1, visible-1)
+	}
+	if m.selected != -1 {
+		row := m.selected / columns
+		if row < m.top {
+			m.top = row
+		} else if row >= m.top+visible {
+			m.top = row - visible + 1
+		}
+	}
+	m.top = min(m.top, rows-visible)
+
+	nameWidth := m.cellWidth() - 2
+	var lines []string
+	for row := m.top; row < m.top+visible; row++ {
+		var line strings.Builder
+		used := 0
+		for col := 0; col < columns; col++ {
+			i := row*columns + col
+			if i >= len(m.candidates) {
+				break
+			}
+			candidate := m.candidates[i]
+			cell := candidate.display + strings.Repeat(" ", nameWidth-displayWidth(candidate.display))
+			if candidate.description != "" {
+				cell += "  -- " + candidate.description
+			}
+			cell = truncateWidth(cell, width-1-used)
+			used += displayWidth(cell)
+			if i == m.selected {
+				cell = "\033[7m" + cell + "\033[0m"
+			}
+			line.WriteString(cell)
+			if col < columns-1 {
+				line.WriteString("  ")
+				used += 2
+			}
+		}
+		lines = append(lines, strings.TrimRight(line.String(), " "))
+	}
+	if visible < rows {
+		lines = append(lines, "\033[7mrows "+strconv.Itoa(m.top+1)+"-"+strconv.Itoa(m.top+visible)+" of "+strconv.Itoa(rows)+"\033[0m")
+	}
+	return strings.Join(lines, "\r\n"), len(lines)
+}
+
+// truncateWidth 截断字符串使其显示宽度不超过 width
+func truncateWidth(s string, width int) string {
+	used := 0
+	for i, r := range s {
+		w := runes.Width(r)
+		if used+w > width {
+			return s[:i]
+		}
+		used += w
+	}
+	return s
+}
+
+// paintMenu 在绘制好的输入行 painted 之后追加菜单：换行输出菜单后把光标移回输入内容 line 的末尾，
+// readline 再从那里按光标位置回退；下一次重绘时 readline 清除光标之后的内容，菜单也随之清除
+func (p *PromptPainter) paintMenu(painted []rune, line []rune) []rune {
+	menu := p.sh.completionMenu
+	if menu == nil || strings.ContainsRune(string(line), '\n') {
+		return painted
+	}
+	width, height := menuSize()
+	used := displayWidth(p.sh.promptLine) + runes.WidthAll(line)
+	body, rows := menu.render(width, height, used/width+1)
+	seq := "\r\n\033[J" + body + "\033[" + strconv.Itoa(rows) + "A\033[" + strconv.Itoa(used%width+1) + "G"
+	return append(append([]rune{}, painted...), []rune(seq)...)
+}
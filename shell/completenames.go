@@ -0,0 +1,126 @@
+package shell
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 补全用户名和主机名时读取的文件
+const (
+	passwdFile     = "/etc/passwd"
+	hostsFile      = "/etc/hosts"
+	knownHostsFile = ".ssh/known_hosts" // 位于 HOME 目录下
+)
+
+// userCandidates 补全 ~ 之后的用户名，用户名来自 /etc/passwd，补全后追加 /
+func userCandidates(value string) []completionCandidate {
+	prefix := value[1:]
+	var candidates []completionCandidate
+	for _, name := range readUserNames() {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, completionCandidate{text: "~" + name, display: "~" + name, suffix: "/"})
+		}
+	}
+	return candidates
+}
+
+// readUserNames 按名称排序返回 /etc/passwd 中的用户名
+func readUserNames() []string {
+	f, err := os.Open(passwdFile)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, _, ok := strings.Cut(line, ":"); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hostPrefix 返回单词中正在输入的主机名之前的 @ 的位置，例如 "root@ser" 返回 4
+// @ 之后出现 / 或 : 时（例如 scp 的 host:path）不是主机名，返回 -1
+func hostPrefix(value string) int {
+	i := strings.LastIndexByte(value, '@')
+	if i == -1 || strings.ContainsAny(value[i+1:], "/:") {
+		return -1
+	}
+	return i
+}
+
+// hostCandidates 补全 @ 之后的主机名，主机名来自 /etc/hosts 和 ~/.ssh/known_hosts
+func (sh *Interp) hostCandidates(value string) []completionCandidate {
+	i := hostPrefix(value)
+	head, prefix := value[:i+1], value[i+1:]
+	var candidates []completionCandidate
+	for _, host := range sh.readHostNames() {
+		if strings.HasPrefix(host, prefix) {
+			candidates = append(candidates, completionCandidate{text: head + host, display: host, suffix: " "})
+		}
+	}
+	return candidates
+}
+
+// readHostNames 按名称排序返回 /etc/hosts 和 ~/.ssh/known_hosts 中去重后的主机名
+func (sh *Interp) readHostNames() []string {
+	seen := make(map[string]bool)
+	for _, fields := range readFields(hostsFile) {
+		// 第一列是地址，之后是主机名和别名
+		for _, host := range fields[1:] {
+			seen[host] = true
+		}
+	}
+	if home := sh.getVar("HOME"); home != "" {
+		for _, fields := range readFields(filepath.Join(home, knownHostsFile)) {
+			// @cert-authority、@revoked 标记之后才是主机列表
+			if strings.HasPrefix(fields[0], "@") {
+				fields = fields[1:]
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			for _, host := range strings.Split(fields[0], ",") {
+				// 经过哈希的主机名无法还原，[host]:port 只取主机名，通配符不是具体的主机
+				if strings.HasPrefix(host, "|") || strings.ContainsAny(host, "*?!") {
+					continue
+				}
+				if strings.HasPrefix(host, "[") {
+					host, _, _ = strings.Cut(host[1:], "]")
+				}
+				if host != "" {
+					seen[host] = true
+				}
+			}
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// readFields 读取文件中的非空行并按空白拆分，忽略 # 开头的注释
+func readFields(path string) [][]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var lines [][]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if fields := strings.Fields(line); len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	return lines
+}
@@ -3,98 +3,482 @@ package shell
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/chzyer/readline"
 	"go_shell/utils"
 )
 
-// CreateCompleter 创建自动补全器
-func CreateCompleter(trie *utils.Trie) readline.AutoCompleter {
-	return &CustomCompleter{trie: trie}
+// CreateCompleter 创建自动补全器，它同时是 readline 的 Listener（用于替换光标前的单词）
+// 和 FuncFilterInputRune（补全菜单打开时处理按键）
+func CreateCompleter(sh *Interp) *CustomCompleter {
+	return &CustomCompleter{sh: sh}
 }
 
 // CustomCompleter 自定义补全器
 type CustomCompleter struct {
-	trie       *utils.Trie
+	sh         *Interp
 	lastPrefix string // 上一次的输入前缀
 	tabPressed bool   // 是否已经按过一次 TAB（针对当前前缀）
+
+	// readline 的 AutoCompleter 只能在光标处插入文本，候选项不以输入开头时（模糊匹配、大小写不同）
+	// 需要替换整个单词：Do 把修改后的输入行保存在这里，由 OnChange 在 TAB 处理完之后设置
+	editLine []rune
+	editPos  int
+}
+
+// completionWord 是正在补全的单词
+type completionWord struct {
+	start int    // 单词在行中的起始位置
+	value string // 去掉引号和转义后的内容
+	quote byte   // 单词中未闭合的引号，0 表示不在引号中
+}
+
+// completionCandidate 是一个补全候选项
+type completionCandidate struct {
+	text    string // 补全后单词的完整内容（未转义）
+	display string // 列出所有候选项时显示的名称
+	suffix  string // 唯一匹配时追加在单词之后的内容：一般是空格，目录和 ~user 是 /，$NAME 不追加，${NAME 是 }
+
+	description string // 菜单中显示在候选项之后的说明，例如 builtin、function 或选项的帮助
+}
+
+// closing 返回补全候选项之后追加的内容：单词在引号中时先闭合引号，再追加 suffix
+func (candidate completionCandidate) closing(word completionWord) string {
+	if candidate.suffix == " " && word.quote != 0 {
+		return string(word.quote) + candidate.suffix
+	}
+	return candidate.suffix
 }
 
+// 文件名补全的范围
+const (
+	completeFiles       = iota // 所有文件
+	completeDirs               // 只补全目录，例如 cd 和 pushd 的参数
+	completeExecutables        // 目录和可执行文件，例如命令位置的 ./build.sh
+)
+
+// Do 补全光标之前的单词，根据它在命令中的位置决定补全内容：
+// 命令位置（行首以及 | && || ; ( { 之后）从命令 Trie 中补全，带路径时补全可执行文件；
+// 以 $ 或 ${ 开头的部分补全变量名，~ 之后补全用户名，参数中 @ 之后补全主机名；
+// complete 注册了规则或有补全规则文件的命令按规则补全参数；
+// 重定向目标和其他参数补全为文件名，cd 和 pushd 的参数只补全目录
 func (c *CustomCompleter) Do(line []rune, pos int) (newLine [][]rune, length int) {
-	lineStr := string(line)
-	parts := strings.Fields(lineStr)
-	if len(parts) == 0 {
+	lineStr := string(line[:pos])
+	if strings.TrimSpace(lineStr) == "" {
 		// 重置状态
 		c.lastPrefix = ""
 		c.tabPressed = false
 		return nil, 0
 	}
-	prefix := parts[0]
-	// 将前缀转换为小写进行查找（保持与存储格式一致）
-	lowerPrefix := strings.ToLower(prefix)
 
-	// 如果前缀改变了，重置 TAB 状态
-	if lowerPrefix != c.lastPrefix {
-		c.lastPrefix = lowerPrefix
+	// 如果输入改变了，重置 TAB 状态
+	if lineStr != c.lastPrefix {
+		c.lastPrefix = lineStr
 		c.tabPressed = false
 	}
 
-	completions := c.trie.FindCompletions(lowerPrefix)
-	if len(completions) == 1 {
+	word, context := analyzeCompletion(lineStr)
+	var prefix string
+	var candidates []completionCandidate
+	// 参数中 @ 之后有匹配的主机名时补全主机名，否则按其他规则补全（例如文件名中的 @）
+	var hosts []completionCandidate
+	if !context.commandPosition && hostPrefix(word.value) != -1 {
+		hosts = c.sh.hostCandidates(word.value)
+	}
+	switch {
+	case word.quote != '\'' && variablePrefix(word.value) != -1:
+		prefix = word.value
+		candidates = c.variableCandidates(word.value)
+	case word.quote == 0 && strings.HasPrefix(word.value, "~") && !strings.Contains(word.value, "/"):
+		prefix = word.value
+		candidates = userCandidates(word.value)
+	case len(hosts) > 0:
+		prefix = word.value
+		candidates = hosts
+	case context.commandPosition && !strings.Contains(word.value, "/"):
+		prefix = word.value
+		candidates = c.commandCandidates(prefix)
+	case context.commandPosition:
+		prefix = word.value
+		candidates = c.sh.fileCandidates(prefix, completeExecutables)
+	case !context.redirectTarget && c.sh.completions[context.command] != nil:
+		// complete 注册了补全规则的命令，规则没有生成候选项时回退到文件名补全
+		prefix = word.value
+		candidates = c.sh.specCandidates(c.sh.completions[context.command], context, word.value, string(line), pos)
+		if len(candidates) == 0 {
+			candidates = c.sh.fileCandidates(prefix, completeFiles)
+		}
+	case !context.redirectTarget && c.sh.completionTrees[context.command] != nil:
+		// 补全规则文件中描述的命令
+		prefix = word.value
+		candidates = c.sh.treeCandidates(c.sh.completionTrees[context.command], context, word.value)
+		if len(candidates) == 0 {
+			candidates = c.sh.fileCandidates(prefix, completeFiles)
+		}
+	case !context.redirectTarget && (context.command == "cd" || context.command == "pushd"):
+		prefix = word.value
+		candidates = c.sh.fileCandidates(prefix, completeDirs)
+	default:
+		prefix = word.value
+		candidates = c.sh.fileCandidates(prefix, completeFiles)
+	}
+	return c.complete(string(line), pos, word, prefix, candidates)
+}
+
+// OnChange 实现 readline.Listener，在按键处理完之后应用 Do 或补全菜单保存的整行修改
+func (c *CustomCompleter) OnChange(line []rune, pos int, key rune) ([]rune, int, bool) {
+	editLine, editPos := c.editLine, c.editPos
+	c.editLine = nil
+	if editLine == nil {
+		return nil, 0, false
+	}
+	return editLine, editPos, true
+}
+
+// commandCandidates 按当前的匹配方式列出与 prefix 匹配的函数、内置命令和 PATH 中的命令
+// 默认区分大小写的前缀匹配直接在 Trie 中查找，其他方式需要检查所有命令名；执行次数多的命令排在前面
+func (c *CustomCompleter) commandCandidates(prefix string) []completionCandidate {
+	trie := c.sh.commandTrie()
+	var entries []utils.Entry
+	if mode, ignoreCase := c.sh.completionMatchMode(prefix); mode == utils.MatchPrefix && !ignoreCase {
+		entries = trie.FindEntries(prefix, 0)
+	} else {
+		entries = trie.FindEntries("", 0)
+	}
+	names := make([]string, 0, len(entries))
+	uses := make(map[string]int)
+	for _, entry := range entries {
+		names = append(names, entry.Word)
+		uses[entry.Word] = entry.Uses
+	}
+	for name := range c.sh.funcs {
+		if !trie.Search(name) {
+			names = append(names, name)
+		}
+	}
+	var candidates []completionCandidate
+	for _, name := range c.sh.rankMatches(prefix, names, uses) {
+		candidate := completionCandidate{text: name, display: name, suffix: " "}
+		switch {
+		case c.sh.funcs[name] != nil:
+			candidate.description = "function"
+		case isBuiltinCommand(name):
+			candidate.description = "builtin"
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// completionMatchMode 返回 shopt 选项 complete_fuzzy、complete_substring 选择的匹配方式，
+// 以及 complete_smartcase 开启且 pattern 中没有大写字母时忽略大小写
+func (sh *Interp) completionMatchMode(pattern string) (utils.MatchMode, bool) {
+	mode := utils.MatchPrefix
+	switch {
+	case sh.options["complete_fuzzy"]:
+		mode = utils.MatchFuzzy
+	case sh.options["complete_substring"]:
+		mode = utils.MatchSubstring
+	}
+	ignoreCase := sh.options["complete_smartcase"] && strings.ToLower(pattern) == pattern
+	return mode, ignoreCase
+}
+
+// rankMatches 筛选出与 pattern 匹配的名称，按匹配得分从高到低排序，
+// 得分相同时 uses 中执行次数多的、最近在历史记录中使用过的、较短的名称排在前面；uses 可以为 nil
+func (sh *Interp) rankMatches(pattern string, names []string, uses map[string]int) []string {
+	mode, ignoreCase := sh.completionMatchMode(pattern)
+	type match struct {
+		name   string
+		score  int
+		recent int
+	}
+	var matches []match
+	for _, name := range names {
+		if score, ok := utils.Match(mode, pattern, name, ignoreCase); ok {
+			rank, used := recentRank(name)
+			if !used {
+				rank = len(HistoryCmdSlice)
+			}
+			matches = append(matches, match{name, score, rank})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if uses[a.name] != uses[b.name] {
+			return uses[a.name] > uses[b.name]
+		}
+		if a.recent != b.recent {
+			return a.recent < b.recent
+		}
+		if len(a.name) != len(b.name) {
+			return len(a.name) < len(b.name)
+		}
+		return a.name < b.name
+	})
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.name
+	}
+	return result
+}
+
+// 排序时只看最近的历史记录
+const recentHistorySize = 500
+
+// recentWordIndex 记录单词（以及路径的最后一部分）最后一次出现在哪条历史记录中（HistoryCmdSlice 的下标），
+// 每加入一条历史记录时更新，补全时不需要重新分析历史记录
+var recentWordIndex = make(map[string]int)
+
+// AddHistory 把命令加入历史记录，并更新补全排序使用的单词记录
+func AddHistory(lines ...string) {
+	start := len(HistoryCmdSlice)
+	HistoryCmdSlice = append(HistoryCmdSlice, lines...)
+	// 一次加入很多行（例如读取历史文件）时只需要分析最后 recentHistorySize 行
+	for i := max(start, len(HistoryCmdSlice)-recentHistorySize); i < len(HistoryCmdSlice); i++ {
+		for _, tok := range lexLine(HistoryCmdSlice[i]) {
+			if tok.kind == tokenWord {
+				recentWordIndex[tok.value] = i
+				recentWordIndex[filepath.Base(tok.value)] = i
+			}
+		}
+	}
+}
+
+// recentRank 返回单词最后一次出现在倒数第几条命令中，越小表示越近；
+// 最近 recentHistorySize 条命令中没有出现时返回 false
+func recentRank(word string) (int, bool) {
+	index, ok := recentWordIndex[word]
+	if !ok || index >= len(HistoryCmdSlice) {
+		return 0, false
+	}
+	rank := len(HistoryCmdSlice) - 1 - index
+	return rank, rank < recentHistorySize
+}
+
+// completionContext 描述正在补全的单词在命令中的位置
+type completionContext struct {
+	commandPosition bool     // 单词是命令名
+	redirectTarget  bool     // 单词是重定向的目标文件
+	command         string   // 单词所在命令的命令名
+	words           []string // 当前命令中的单词（不含重定向），最后一个是正在补全的单词，即 COMP_WORDS
+	cword           int      // 正在补全的单词在 words 中的下标，即 COMP_CWORD
+}
+
+// analyzeCompletion 用 lexLine 拆分光标之前的输入，返回正在补全的单词及其位置
+// 光标前是空白或操作符时，正在补全的是一个新的空单词
+func analyzeCompletion(input string) (completionWord, completionContext) {
+	tokens := lexLine(input)
+	word := completionWord{start: len(input)}
+	if n := len(tokens); n > 0 && tokens[n-1].kind == tokenWord && tokens[n-1].end == len(input) {
+		last := tokens[n-1]
+		word = completionWord{start: last.start, value: last.value, quote: last.quote}
+		tokens = tokens[:n-1]
+	}
+
+	var context completionContext
+	if n := len(tokens); n > 0 && tokens[n-1].kind == tokenRedirect {
+		context.redirectTarget = true
+	}
+	// 从当前命令的开头（最近的控制操作符或 {、} 之后）开始，跳过重定向和前置的变量赋值
+	start := len(tokens)
+	for start > 0 && tokens[start-1].kind != tokenOperator && !isGroupWord(tokens[start-1]) {
+		start--
+	}
+	for i := start; i < len(tokens); i++ {
+		switch {
+		case tokens[i].kind == tokenRedirect:
+			i++
+		case context.command == "" && isAssignment(tokens[i].value):
+		case context.command == "":
+			context.command = tokens[i].value
+			context.words = append(context.words, tokens[i].value)
+		default:
+			context.words = append(context.words, tokens[i].value)
+		}
+	}
+	context.commandPosition = context.command == "" && !context.redirectTarget
+	context.cword = len(context.words)
+	context.words = append(context.words, word.value)
+	return word, context
+}
+
+// isGroupWord 判断单词是否是命令组的 { 或 }，它们之后是新的命令
+func isGroupWord(tok token) bool {
+	return tok.kind == tokenWord && (tok.text == "{" || tok.text == "}")
+}
+
+// variablePrefix 返回单词末尾正在输入的变量名之前的 $ 的位置，例如 "a$HO" 和 "a${HO" 都返回 1
+// 单词末尾不是变量名时返回 -1
+func variablePrefix(value string) int {
+	i := strings.LastIndexByte(value, '$')
+	if i == -1 {
+		return -1
+	}
+	name := strings.TrimPrefix(value[i+1:], "{")
+	if name != "" && !isValidName(name) {
+		return -1
+	}
+	return i
+}
+
+// variableCandidates 列出与单词末尾的 $NAME 或 ${NAME 匹配的变量，${ 形式补全后追加 }
+func (c *CustomCompleter) variableCandidates(value string) []completionCandidate {
+	i := variablePrefix(value)
+	head, namePrefix := value[:i+1], value[i+1:]
+	suffix := ""
+	if strings.HasPrefix(namePrefix, "{") {
+		head, namePrefix, suffix = head+"{", namePrefix[1:], "}"
+	}
+	names := c.sh.variableNames(namePrefix)
+	candidates := make([]completionCandidate, len(names))
+	for j, name := range names {
+		candidates[j] = completionCandidate{text: head + name, display: "$" + name, suffix: suffix}
+	}
+	return candidates
+}
+
+// complete 根据候选项补全单词：唯一匹配时补全整个单词，多个匹配时补全到最长公共前缀，
+// 无法继续补全时第一次按 TAB 响铃，第二次打开候选项菜单
+// line 是整行输入，pos 是光标位置，补全的内容插入在光标处
+func (c *CustomCompleter) complete(line string, pos int, word completionWord, prefix string, candidates []completionCandidate) ([][]rune, int) {
+	if len(candidates) == 1 {
 		// 重置状态（因为找到了唯一匹配）
 		c.tabPressed = false
-		completion := completions[0]
-		if completion != "" && completion != lowerPrefix {
-			// 找到有效的补全，返回需要追加的部分
-			remaining := completion[len(lowerPrefix):] + " "
-			return [][]rune{[]rune(remaining)}, len(remaining)
-		}
-		if completion == lowerPrefix {
+		candidate := candidates[0]
+		if candidate.text == prefix && (candidate.suffix == " " || candidate.suffix == "") {
 			// 命令已经完整（用户输入的就是完整的有效命令），不需要补全，也不输出铃声
 			return nil, 0
 		}
-	} else if len(completions) > 1 {
-		// 多个匹配：计算最长公共前缀
-		commonPrefix := longestCommonPrefix(completions)
-
-		// 如果最长公共前缀比用户输入的前缀长，则补全到最长公共前缀
-		if len(commonPrefix) > len(lowerPrefix) {
-			// 补全到最长公共前缀
-			remaining := commonPrefix[len(lowerPrefix):]
-			// 补全后仍有多个匹配，只补全到最长公共前缀
-			c.tabPressed = false
-			return [][]rune{[]rune(remaining)}, len(remaining)
-		}
-
-		// 最长公共前缀等于用户输入的前缀，无法进一步补全
-		// 按原来的逻辑处理：第一次响铃，第二次显示列表
-		sortedCompletions := make([]string, len(completions))
-		copy(sortedCompletions, completions)
-		sort.Strings(sortedCompletions)
-		if !c.tabPressed {
-			// 第一次按 TAB：响铃
-			c.tabPressed = true
-			fmt.Fprint(os.Stdout, "\x07")
-		} else {
-			// 第二次按 TAB：打印所有匹配的可执行文件
-			// 用2个空格分隔，打印在下一行
-			listStr := strings.Join(sortedCompletions, "  ")
-			fmt.Fprintf(os.Stdout, "\n%s\n", listStr)
-			// 在新行显示提示符和用户输入的内容
-			fmt.Fprintf(os.Stdout, "$ %s", lineStr)
-			// 重置状态
-			c.tabPressed = false
+		closing := candidate.closing(word)
+		if !strings.HasPrefix(candidate.text, prefix) {
+			// 模糊匹配等候选项不以输入开头，替换整个单词
+			return c.replaceWord(line, pos, word, candidate.text, closing)
 		}
-	} else {
+		// 找到有效的补全，返回需要追加的部分
+		remaining := escapeCompletion(candidate.text[len(prefix):], word.quote) + closing
+		return [][]rune{[]rune(remaining)}, len(remaining)
+	}
+	if len(candidates) == 0 {
 		// 没有匹配：响铃
 		c.tabPressed = false
 		fmt.Fprint(os.Stdout, "\x07")
+		return nil, 0
+	}
+
+	// 多个匹配：计算最长公共前缀
+	texts := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		texts[i] = candidate.text
+	}
+	commonPrefix := longestCommonPrefix(texts)
+	// 按字节比较得到的公共前缀可能截断多字节字符
+	for !utf8.ValidString(commonPrefix) {
+		commonPrefix = commonPrefix[:len(commonPrefix)-1]
+	}
+
+	// 候选项不都以输入开头时（模糊匹配、忽略大小写），公共前缀不短于输入就用它替换整个单词，
+	// 例如忽略大小写时 rs 替换为 Rs
+	if !strings.HasPrefix(commonPrefix, prefix) && utf8.RuneCountInString(commonPrefix) >= utf8.RuneCountInString(prefix) {
+		c.tabPressed = false
+		return c.replaceWord(line, pos, word, commonPrefix, "")
+	}
+	// 如果最长公共前缀比用户输入的前缀长，则补全到最长公共前缀
+	if len(commonPrefix) > len(prefix) {
+		// 补全后仍有多个匹配，只补全到最长公共前缀
+		remaining := escapeCompletion(commonPrefix[len(prefix):], word.quote)
+		c.tabPressed = false
+		return [][]rune{[]rune(remaining)}, len(remaining)
+	}
+
+	// 最长公共前缀等于用户输入的前缀，无法进一步补全：第一次响铃，第二次打开候选项菜单
+	if !c.tabPressed {
+		c.tabPressed = true
+		fmt.Fprint(os.Stdout, "\x07")
+		return nil, 0
 	}
+	c.tabPressed = false
+	return c.openMenu(line, pos, word, candidates)
+}
+
+// replaceWord 把光标前的单词替换为 text（按单词原来的引号转义），closing 追加在之后
+// 修改后的输入行由 OnChange 设置，这里不插入任何内容
+func (c *CustomCompleter) replaceWord(line string, pos int, word completionWord, text string, closing string) ([][]rune, int) {
+	before := []rune(line[:word.start])
+	if word.quote != 0 {
+		text = string(word.quote) + escapeCompletion(text, word.quote)
+	} else {
+		text = escapeCompletion(text, 0)
+	}
+	replaced := append(before, []rune(text+closing)...)
+	c.editPos = len(replaced)
+	c.editLine = append(replaced, []rune(line)[pos:]...)
 	return nil, 0
 }
 
+// fileCandidates 按当前的匹配方式列出与 value 匹配的文件，相对路径相对于解释器的工作目录，支持 ~ 开头的路径
+// 除非 value 的最后一部分以 . 开头，否则不列出隐藏文件；mode 为 completeFiles、completeDirs 或 completeExecutables
+func (sh *Interp) fileCandidates(value string, mode int) []completionCandidate {
+	dirPart, base := "", value
+	if i := strings.LastIndex(value, "/"); i != -1 {
+		dirPart, base = value[:i+1], value[i+1:]
+	}
+	dir := sh.resolvePath(sh.expandTilde(dirPart))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if name := entry.Name(); !strings.HasPrefix(name, ".") || strings.HasPrefix(base, ".") {
+			names = append(names, name)
+		}
+	}
+	var candidates []completionCandidate
+	for _, name := range sh.rankMatches(base, names, nil) {
+		// 指向目录的符号链接也按目录处理
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			candidates = append(candidates, completionCandidate{text: dirPart + name, display: name + "/", suffix: "/"})
+			continue
+		}
+		if mode == completeDirs || (mode == completeExecutables && !utils.IsExecutable(info)) {
+			continue
+		}
+		candidates = append(candidates, completionCandidate{text: dirPart + name, display: name, suffix: " "})
+	}
+	return candidates
+}
+
+// escapeCompletion 转义补全插入的文本，使其在当前的引号状态下保持原样
+func escapeCompletion(s string, quote byte) string {
+	special := " \t'\"\\$|&;()<>*?[]#`{}!"
+	switch quote {
+	case '\'':
+		return s
+	case '"':
+		special = "\"\\$`"
+	}
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(special, s[i]) != -1 {
+			result.WriteByte('\\')
+		}
+		result.WriteByte(s[i])
+	}
+	return result.String()
+}
+
 // longestCommonPrefix 计算字符串数组的最长公共前缀
 func longestCommonPrefix(strs []string) string {
 	if len(strs) == 0 {
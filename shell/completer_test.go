@@ -0,0 +1,37 @@
+package shell
+
+import "testing"
+
+// 加入历史记录时更新单词的最近使用位置，超过 recentHistorySize 条之前的单词不再算作最近使用
+func TestRecentRank(t *testing.T) {
+	saved, savedIndex := HistoryCmdSlice, recentWordIndex
+	defer func() { HistoryCmdSlice, recentWordIndex = saved, savedIndex }()
+	HistoryCmdSlice, recentWordIndex = nil, make(map[string]int)
+
+	AddHistory("make build", "/usr/bin/gofmt -l .", "git status")
+	tests := []struct {
+		word string
+		rank int
+		ok   bool
+	}{
+		{"git", 0, true},
+		{"gofmt", 1, true},
+		{"make", 2, true},
+		{"cargo", 0, false},
+	}
+	for _, tt := range tests {
+		if rank, ok := recentRank(tt.word); rank != tt.rank || ok != tt.ok {
+			t.Errorf("recentRank(%q) = %d, %v; want %d, %v", tt.word, rank, ok, tt.rank, tt.ok)
+		}
+	}
+
+	for i := 0; i < recentHistorySize; i++ {
+		AddHistory("ls")
+	}
+	if _, ok := recentRank("make"); ok {
+		t.Errorf("make is older than %d commands but still ranked as recent", recentHistorySize)
+	}
+	if rank, ok := recentRank("ls"); rank != 0 || !ok {
+		t.Errorf("recentRank(ls) = %d, %v; want 0, true", rank, ok)
+	}
+}
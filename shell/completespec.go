@@ -0,0 +1,280 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go_shell/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// 声明式补全规则文件所在的目录，位于 HOME 目录下，支持 .yaml、.yml 和 .json 文件
+const completionSpecDir = ".config/goshell/completions"
+
+// specCommand 是补全规则文件中的一个命令或子命令，例如：
+//
+//	name: deploy
+//	description: Deploy services
+//	flags:
+//	  - name: --env
+//	    aliases: [-e]
+//	    arg: {values: [dev, staging, prod]}
+//	  - name: --config
+//	    arg: {type: file}
+//	subcommands:
+//	  - name: rollback
+//	    args: {command: "cat releases.txt"}
+type specCommand struct {
+	Name        string        `yaml:"name"`
+	Aliases     []string      `yaml:"aliases"`
+	Description string        `yaml:"description"`
+	Flags       []specFlag    `yaml:"flags"`
+	Subcommands []specCommand `yaml:"subcommands"`
+	Args        *specArg      `yaml:"args"` // 位置参数
+}
+
+// specFlag 是命令的一个选项，Arg 不为空时选项带一个参数
+type specFlag struct {
+	Name        string   `yaml:"name"`
+	Aliases     []string `yaml:"aliases"`
+	Description string   `yaml:"description"`
+	Arg         *specArg `yaml:"arg"`
+}
+
+// specArg 描述参数的取值：Type 为 file、directory、command 或 none（不带参数），
+// Values 是固定的取值，Command 是生成取值的命令（由 sh -c 执行，有超时），输出的每一行是一个取值
+type specArg struct {
+	Type    string   `yaml:"type"`
+	Values  []string `yaml:"values"`
+	Command string   `yaml:"command"`
+}
+
+// completionTree 是编译后的命令补全树，子命令和选项名保存在 Trie 中用于前缀匹配
+type completionTree struct {
+	description string
+	subcommands *utils.Trie
+	children    map[string]*completionTree // 子命令，别名指向同一个节点
+	flags       *utils.Trie
+	flagArgs    map[string]*specArg // 选项名对应的参数，不带参数的选项为 nil
+	flagHelp    map[string]string   // 选项名对应的说明，显示在补全菜单中
+	args        *specArg
+}
+
+// LoadCompletionSpecs 启动时加载 ~/.config/goshell/completions/ 中的补全规则文件，
+// 每个文件描述一个命令；文件有错误时输出错误并跳过
+func (sh *Interp) LoadCompletionSpecs() {
+	home := sh.getVar("HOME")
+	if home == "" {
+		return
+	}
+	dir := filepath.Join(home, completionSpecDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	_, _, stderr := sh.stdio()
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if err := sh.loadCompletionSpec(path); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		}
+	}
+}
+
+// loadCompletionSpec 解析一个补全规则文件（JSON 是 YAML 的子集，使用同一个解析器），
+// 编译后按命令名和别名注册
+func (sh *Interp) loadCompletionSpec(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var spec specCommand
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return err
+	}
+	if spec.Name == "" {
+		return fmt.Errorf("missing command name")
+	}
+	tree, err := compileCompletionTree(&spec)
+	if err != nil {
+		return err
+	}
+	for _, name := range append([]string{spec.Name}, spec.Aliases...) {
+		sh.completionTrees[name] = tree
+	}
+	return nil
+}
+
+// compileCompletionTree 把规则编译为补全树，检查参数类型是否合法
+func compileCompletionTree(spec *specCommand) (*completionTree, error) {
+	tree := &completionTree{
+		description: spec.Description,
+		subcommands: utils.Constructor(),
+		children:    make(map[string]*completionTree),
+		flags:       utils.Constructor(),
+		flagArgs:    make(map[string]*specArg),
+		flagHelp:    make(map[string]string),
+		args:        spec.Args,
+	}
+	if err := checkSpecArg(spec.Name, spec.Args); err != nil {
+		return nil, err
+	}
+	for i := range spec.Flags {
+		flag := &spec.Flags[i]
+		if err := checkSpecArg(flag.Name, flag.Arg); err != nil {
+			return nil, err
+		}
+		for _, name := range append([]string{flag.Name}, flag.Aliases...) {
+			if !strings.HasPrefix(name, "-") {
+				return nil, fmt.Errorf("%s: flag name must start with -", name)
+			}
+			tree.flags.Insert(name)
+			tree.flagArgs[name] = flag.Arg
+			tree.flagHelp[name] = flag.Description
+		}
+	}
+	for i := range spec.Subcommands {
+		sub := &spec.Subcommands[i]
+		if sub.Name == "" {
+			return nil, fmt.Errorf("%s: subcommand without name", spec.Name)
+		}
+		child, err := compileCompletionTree(sub)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+			tree.subcommands.Insert(name)
+			tree.children[name] = child
+		}
+	}
+	return tree, nil
+}
+
+// takesValue 判断选项是否带参数：没有 arg 或类型为 none 的选项不带参数，不会占用下一个单词
+func (arg *specArg) takesValue() bool {
+	return arg != nil && arg.Type != "none"
+}
+
+func checkSpecArg(name string, arg *specArg) error {
+	if arg == nil {
+		return nil
+	}
+	switch arg.Type {
+	case "", "file", "directory", "command", "none":
+		return nil
+	}
+	return fmt.Errorf("%s: unknown argument type %q", name, arg.Type)
+}
+
+// treeCandidates 按补全树补全 words[cword]：先沿着已输入的子命令向下查找，
+// 前一个单词是带参数的选项时补全选项的参数（也支持 --flag=value），
+// 以 - 开头时补全选项，否则补全子命令和位置参数
+func (sh *Interp) treeCandidates(tree *completionTree, context completionContext, word string) []completionCandidate {
+	var pending *specArg
+	for _, w := range context.words[1:context.cword] {
+		if pending != nil {
+			pending = nil
+			continue
+		}
+		if child, ok := tree.children[w]; ok {
+			tree = child
+			continue
+		}
+		if arg := tree.flagArgs[w]; arg.takesValue() {
+			pending = arg
+		}
+	}
+
+	if pending != nil {
+		return sh.specArgCandidates(pending, "", word)
+	}
+	if name, value, ok := strings.Cut(word, "="); ok && strings.HasPrefix(word, "-") {
+		if arg := tree.flagArgs[name]; arg.takesValue() {
+			return sh.specArgCandidates(arg, name+"=", value)
+		}
+		return nil
+	}
+
+	var candidates []completionCandidate
+	if strings.HasPrefix(word, "-") {
+		names := tree.flags.FindCompletions(word, 0)
+		for _, name := range names {
+			candidates = append(candidates, completionCandidate{text: name, display: name, suffix: " ", description: tree.flagHelp[name]})
+		}
+		return candidates
+	}
+	names := tree.subcommands.FindCompletions(word, 0)
+	for _, name := range names {
+		candidates = append(candidates, completionCandidate{text: name, display: name, suffix: " ", description: tree.children[name].description})
+	}
+	if tree.args != nil {
+		candidates = append(candidates, sh.specArgCandidates(tree.args, "", word)...)
+	}
+	return candidates
+}
+
+// specArgCandidates 列出参数取值中以 word 开头的候选项，head 是取值之前的部分（例如 --env=）
+func (sh *Interp) specArgCandidates(arg *specArg, head string, word string) []completionCandidate {
+	var candidates []completionCandidate
+	switch arg.Type {
+	case "file":
+		candidates = sh.fileCandidates(word, completeFiles)
+	case "directory":
+		candidates = sh.fileCandidates(word, completeDirs)
+	case "command":
+		names := sh.commandTrie().FindCompletions(word, 0)
+		for _, name := range names {
+			candidates = append(candidates, completionCandidate{text: name, display: name, suffix: " "})
+		}
+	}
+	values := arg.Values
+	if arg.Command != "" {
+		values = append(slices.Clone(values), sh.captureLines(arg.Command)...)
+	}
+	for _, value := range values {
+		if strings.HasPrefix(value, word) {
+			candidates = append(candidates, completionCandidate{text: value, display: value, suffix: " "})
+		}
+	}
+	for i := range candidates {
+		candidates[i].text = head + candidates[i].text
+	}
+	return candidates
+}
+
+// 补全规则中生成取值的命令最多运行的时间，超时视为没有取值，避免无法连接的服务卡住输入行
+const specCommandTimeout = 1500 * time.Millisecond
+
+// captureLines 用 sh -c 在 Shell 的工作目录和环境中执行命令，返回标准输出中的非空行，错误输出被丢弃
+// 命令超过 specCommandTimeout 没有结束时被终止，返回 nil
+func (sh *Interp) captureLines(command string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), specCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = sh.dir
+	cmd.Env = sh.environ(nil)
+	// 命令启动的后台进程可能继续持有输出管道，终止后不再等待它们
+	cmd.WaitDelay = 100 * time.Millisecond
+	out, err := cmd.Output()
+	if ctx.Err() != nil || (err != nil && len(out) == 0) {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,58 @@
+package shell
+
+import (
+	"testing"
+	"time"
+)
+
+// 生成取值的命令超时后视为没有候选项，不会卡住补全
+func TestCaptureLinesTimeout(t *testing.T) {
+	sh := NewInterp()
+	if got := sh.captureLines("printf 'a\\nb\\n'"); len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("captureLines = %q, want [a b]", got)
+	}
+	start := time.Now()
+	if got := sh.captureLines("echo early; sleep 10"); got != nil {
+		t.Errorf("captureLines after timeout = %q, want nil", got)
+	}
+	if elapsed := time.Since(start); elapsed > specCommandTimeout+2*time.Second {
+		t.Errorf("captureLines took %v", elapsed)
+	}
+}
+
+// type: none 的选项不带参数，之后的单词照常补全子命令
+func TestSpecFlagWithoutValue(t *testing.T) {
+	spec := &specCommand{
+		Name:        "tool",
+		Flags:       []specFlag{{Name: "--verbose", Arg: &specArg{Type: "none"}}, {Name: "--env", Arg: &specArg{Values: []string{"dev", "prod"}}}},
+		Subcommands: []specCommand{{Name: "deploy"}},
+	}
+	tree, err := compileCompletionTree(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh := NewInterp()
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{[]string{"tool", "--verbose", "de"}, "deploy"},
+		{[]string{"tool", "--env", "de"}, "dev"},
+	}
+	for _, tt := range tests {
+		context := completionContext{words: tt.words, cword: len(tt.words) - 1}
+		got := sh.treeCandidates(tree, context, tt.words[len(tt.words)-1])
+		if len(got) != 1 || got[0].text != tt.want {
+			t.Errorf("%q: got %v, want %s", tt.words, got, tt.want)
+		}
+	}
+}
+
+// -F 指定的函数执行期间设置的 COMP_* 和 COMPREPLY 在补全结束后恢复，不会留在 Shell 中
+func TestCompletionFunctionVars(t *testing.T) {
+	sh := NewInterp()
+	script := `_f() { COMPREPLY=(alpha "$COMP_CWORD"); }; COMP_LINE=keep; compgen -F _f a; echo "$COMP_LINE|$COMP_WORDS|$COMP_CWORD|${#COMPREPLY[@]}"`
+	if got, want := runTest(t, sh, script), "alpha\n1\nkeep|||0\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,230 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 目录栈：栈顶始终是当前工作目录 sh.dir，sh.dirStack 保存其余目录
+// 通过 DIRSTACK 数组可以读取整个栈，例如 ${DIRSTACK[1]}
+
+// fullDirStack 返回包含当前目录在内的完整目录栈
+func (sh *Interp) fullDirStack() []string {
+	return append([]string{sh.dir}, sh.dirStack...)
+}
+
+// parseStackIndex 解析 +N / -N 形式的栈下标，+N 从左（栈顶）数，-N 从右数
+func parseStackIndex(arg string, size int) (int, bool, error) {
+	if len(arg) < 2 || (arg[0] != '+' && arg[0] != '-') {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil {
+		return 0, false, nil
+	}
+	if n < 0 || n >= size {
+		return 0, true, fmt.Errorf("%s: directory stack index out of range", arg)
+	}
+	if arg[0] == '-' {
+		n = size - 1 - n
+	}
+	return n, true, nil
+}
+
+// abbreviateHome 将 HOME 前缀缩写为 ~
+func (sh *Interp) abbreviateHome(path string) string {
+	home := sh.getVar("HOME")
+	if home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if strings.HasPrefix(path, home+"/") {
+		return "~" + path[len(home):]
+	}
+	return path
+}
+
+// 处理 pushd 命令
+//
+//	pushd [-n] dir   将当前目录压栈并切换到 dir
+//	pushd [-n] +N/-N 旋转目录栈，使第 N 个目录成为栈顶
+//	pushd            交换栈顶的两个目录
+func runPushdBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	noChange := false
+	args := cmdSlice[1:]
+	if len(args) > 0 && args[0] == "-n" {
+		noChange = true
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(errorWriter, "pushd: too many arguments")
+		return 1
+	}
+
+	stack := sh.fullDirStack()
+	if len(args) == 0 {
+		if len(stack) < 2 {
+			fmt.Fprintln(errorWriter, "pushd: no other directory")
+			return 1
+		}
+		stack[0], stack[1] = stack[1], stack[0]
+		return sh.applyDirStack(stack, noChange, "pushd", writer, errorWriter)
+	}
+
+	if index, ok, err := parseStackIndex(args[0], len(stack)); ok {
+		if err != nil {
+			fmt.Fprintf(errorWriter, "pushd: %v\n", err)
+			return 1
+		}
+		rotated := append(stack[index:], stack[:index]...)
+		return sh.applyDirStack(rotated, noChange, "pushd", writer, errorWriter)
+	}
+
+	targetPath := args[0]
+	fullPath, _, err := sh.findDir(targetPath)
+	if err != nil {
+		fmt.Fprintf(errorWriter, "pushd: %s: %s\n", args[0], dirErrorText(err))
+		return 1
+	}
+	if noChange {
+		// -n 只把目录放到当前目录之后，不切换目录
+		sh.dirStack = append([]string{fullPath}, sh.dirStack...)
+	} else {
+		sh.dirStack = stack
+		sh.changeDir(fullPath, false)
+	}
+	return runDirsBuiltin(sh, []string{"dirs"}, writer, errorWriter)
+}
+
+// 处理 popd 命令
+//
+//	popd [-n]        弹出栈顶并切换到新的栈顶
+//	popd [-n] +N/-N  删除第 N 个目录
+func runPopdBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	noChange := false
+	args := cmdSlice[1:]
+	if len(args) > 0 && args[0] == "-n" {
+		noChange = true
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(errorWriter, "popd: too many arguments")
+		return 1
+	}
+
+	stack := sh.fullDirStack()
+	if len(stack) < 2 {
+		fmt.Fprintln(errorWriter, "popd: directory stack empty")
+		return 1
+	}
+
+	index := 0
+	if len(args) == 1 {
+		n, ok, err := parseStackIndex(args[0], len(stack))
+		if !ok {
+			fmt.Fprintf(errorWriter, "popd: %s: invalid argument\n", args[0])
+			fmt.Fprintln(errorWriter, "popd: usage: popd [-n] [+N | -N]")
+			return 2
+		}
+		if err != nil {
+			fmt.Fprintf(errorWriter, "popd: %v\n", err)
+			return 1
+		}
+		index = n
+	}
+	// -n 时保留当前目录，删除的是它之后的那一项
+	if noChange && index == 0 {
+		index = 1
+	}
+	stack = append(stack[:index], stack[index+1:]...)
+	return sh.applyDirStack(stack, noChange, "popd", writer, errorWriter)
+}
+
+// applyDirStack 用新的目录栈替换当前栈，并切换到新的栈顶（noChange 时保持当前目录）
+func (sh *Interp) applyDirStack(stack []string, noChange bool, name string, writer io.Writer, errorWriter io.Writer) int {
+	if noChange {
+		sh.dirStack = stack[1:]
+		return runDirsBuiltin(sh, []string{"dirs"}, writer, errorWriter)
+	}
+	if _, _, err := sh.findDir(stack[0]); err != nil {
+		fmt.Fprintf(errorWriter, "%s: %s: %s\n", name, stack[0], dirErrorText(err))
+		return 1
+	}
+	sh.dirStack = stack[1:]
+	sh.changeDir(stack[0], false)
+	return runDirsBuiltin(sh, []string{"dirs"}, writer, errorWriter)
+}
+
+// 处理 dirs 命令
+//
+//	-c 清空目录栈  -l 不缩写 ~  -p 每行一个  -v 每行一个并带序号  +N/-N 只显示第 N 个
+func runDirsBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	longFormat, perLine, verbose := false, false, false
+	index := -1
+	stack := sh.fullDirStack()
+
+	for _, arg := range cmdSlice[1:] {
+		if n, ok, err := parseStackIndex(arg, len(stack)); ok {
+			if err != nil {
+				fmt.Fprintf(errorWriter, "dirs: %v\n", err)
+				return 1
+			}
+			index = n
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") || len(arg) < 2 {
+			fmt.Fprintf(errorWriter, "dirs: %s: invalid argument\n", arg)
+			fmt.Fprintln(errorWriter, "dirs: usage: dirs [-clpv] [+N] [-N]")
+			return 1
+		}
+		for _, flag := range arg[1:] {
+			switch flag {
+			case 'c':
+				sh.dirStack = nil
+				return 0
+			case 'l':
+				longFormat = true
+			case 'p':
+				perLine = true
+			case 'v':
+				perLine, verbose = true, true
+			default:
+				fmt.Fprintf(errorWriter, "dirs: -%c: invalid option\n", flag)
+				fmt.Fprintln(errorWriter, "dirs: usage: dirs [-clpv] [+N] [-N]")
+				return 1
+			}
+		}
+	}
+
+	format := func(dir string) string {
+		if longFormat {
+			return dir
+		}
+		return sh.abbreviateHome(dir)
+	}
+
+	if index >= 0 {
+		fmt.Fprintln(writer, format(stack[index]))
+		return 0
+	}
+	if !perLine {
+		entries := make([]string, len(stack))
+		for i, dir := range stack {
+			entries[i] = format(dir)
+		}
+		fmt.Fprintln(writer, strings.Join(entries, " "))
+		return 0
+	}
+	for i, dir := range stack {
+		if verbose {
+			fmt.Fprintf(writer, "%2d  %s\n", i, format(dir))
+		} else {
+			fmt.Fprintln(writer, format(dir))
+		}
+	}
+	return 0
+}
@@ -1,152 +1,253 @@
 package shell
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"os/user"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"go_shell/utils"
 )
 
-// 处理CD命令  cmdSlice 0:cd 1:dir
-func HandleCD(cmdSlice []string, errorFile *os.File, errorAppendFile *os.File) bool {
-	errorWriter := os.Stderr
-	if errorFile != nil {
-		errorWriter = errorFile
-	} else if errorAppendFile != nil {
-		errorWriter = errorAppendFile
+// 处理CD命令  cmdSlice 0:cd 1:[-L|-P] 2:dir
+// 目录只保存在解释器状态中，不调用 os.Chdir，避免并发执行的内置命令和子 Shell 相互影响
+// 默认按逻辑路径（-L）处理 ..，-P 则解析符号链接得到物理路径
+func runCDBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	physical := false
+	args := cmdSlice[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-" {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'L':
+				physical = false
+			case 'P':
+				physical = true
+			default:
+				fmt.Fprintf(errorWriter, "cd: -%c: invalid option\n", flag)
+				fmt.Fprintln(errorWriter, "cd: usage: cd [-L|-P] [dir]")
+				return 2
+			}
+		}
+		args = args[1:]
 	}
-
-	if len(cmdSlice) < 2 {
-		return false
+	if len(args) > 1 {
+		fmt.Fprintln(errorWriter, "cd: too many arguments")
+		return 1
 	}
 
-	targetPath := cmdSlice[1]
-	if targetPath == "~" {
-		homePath := os.Getenv("HOME")
-		if homePath == "" {
+	// 没有参数时切换到 HOME，"-" 切换到 OLDPWD 并打印新目录
+	printDir := false
+	var targetPath string
+	switch {
+	case len(args) == 0:
+		targetPath = sh.getVar("HOME")
+		if targetPath == "" {
 			fmt.Fprintf(errorWriter, "cd: HOME not set\n")
-			return false
+			return 1
 		}
-		err := os.Chdir(homePath)
-		if err != nil {
-			fmt.Fprintf(errorWriter, "cd: %s: No such file or directory\n", homePath)
-			return false
+	case args[0] == "-":
+		targetPath = sh.getVar("OLDPWD")
+		if targetPath == "" {
+			fmt.Fprintf(errorWriter, "cd: OLDPWD not set\n")
+			return 1
 		}
-		return true
+		printDir = true
+	default:
+		targetPath = args[0]
+	}
+
+	fullPath, viaCDPath, err := sh.findDir(targetPath)
+	if err != nil {
+		fmt.Fprintf(errorWriter, "cd: %s: %s\n", targetPath, dirErrorText(err))
+		return 1
+	}
+	if err := sh.changeDir(fullPath, physical); err != nil {
+		fmt.Fprintf(errorWriter, "cd: %s: %v\n", targetPath, err)
+		return 1
 	}
+	// 通过 CDPATH 找到的目录需要打印出来
+	if printDir || viaCDPath {
+		fmt.Fprintln(writer, sh.dir)
+	}
+	return 0
+}
 
+// findDir 解析 cd/pushd 的目标目录，返回绝对路径以及是否通过 CDPATH 找到
+func (sh *Interp) findDir(targetPath string) (string, bool, error) {
 	// 判断是绝对路径还是相对路径
 	var fullPath string
+	viaCDPath := false
 	if filepath.IsAbs(targetPath) {
 		// 绝对路径：直接使用
 		fullPath = targetPath
+	} else if cdPath, ok := sh.searchCDPath(targetPath); ok {
+		fullPath = cdPath
+		viaCDPath = true
 	} else {
 		// 相对路径：需要与当前工作目录组合
-		wd, err := os.Getwd()
-		if err != nil {
-			fmt.Fprintf(errorWriter, "Error getting current directory: %v\n", err)
-			return false
-		}
-		fullPath = filepath.Join(wd, targetPath)
+		fullPath = sh.resolvePath(targetPath)
 	}
 
 	// 检查目录是否存在
 	fileInfo, err := os.Stat(fullPath)
 	if err != nil {
-		// 目录不存在，打印错误信息
-		fmt.Fprintf(errorWriter, "cd: %s: No such file or directory\n", targetPath)
-		return false
+		return "", false, err
 	}
-
 	// 检查是否是目录
 	if !fileInfo.IsDir() {
-		fmt.Fprintf(errorWriter, "cd: %s: No such file or directory\n", targetPath)
-		return false
+		return "", false, &fs.PathError{Op: "chdir", Path: fullPath, Err: syscall.ENOTDIR}
 	}
+	return fullPath, viaCDPath, nil
+}
 
-	// 尝试切换目录
-	err = os.Chdir(fullPath)
-	if err != nil {
-		fmt.Fprintf(errorWriter, "cd: %s: No such file or directory\n", targetPath)
-		return false
+// dirErrorText 返回 cd、pushd、popd 报告 findDir 的错误时使用的 bash 风格描述
+func dirErrorText(err error) string {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "No such file or directory"
+	case errors.Is(err, syscall.ENOTDIR):
+		return "Not a directory"
+	case errors.Is(err, fs.ErrPermission):
+		return "Permission denied"
 	}
-
-	return true
+	return err.Error()
 }
 
-// 处理 exit 命令
-func HandleExit() bool {
-	SaveCmdHistoryToEnvFile()
-	return true // 返回 true 表示应该退出循环
+// searchCDPath 在 CDPATH 中查找相对目录，只有以 / . .. 开头以外的路径才会搜索
+// 只有通过 CDPATH 中的非空条目找到时才返回 true，空条目等价于当前目录
+func (sh *Interp) searchCDPath(targetPath string) (string, bool) {
+	cdPathEnv := sh.getVar("CDPATH")
+	if cdPathEnv == "" || targetPath == "." || targetPath == ".." ||
+		strings.HasPrefix(targetPath, "./") || strings.HasPrefix(targetPath, "../") {
+		return "", false
+	}
+	for _, dir := range strings.Split(cdPathEnv, string(os.PathListSeparator)) {
+		if dir == "" {
+			if info, err := os.Stat(sh.resolvePath(targetPath)); err == nil && info.IsDir() {
+				return "", false
+			}
+			continue
+		}
+		candidate := filepath.Join(sh.resolvePath(dir), targetPath)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
 }
 
-// 处理 echo 命令
-func HandleEcho(realCommand string, outputFile *os.File, appendFile *os.File) bool {
-	// 找到 "echo " 之后的内容（包括空格）
-	echoPrefix := "echo "
-	if !strings.HasPrefix(realCommand, echoPrefix) {
-		return false
+// changeDir 切换解释器的工作目录，并更新 PWD 和 OLDPWD
+// 逻辑模式下直接按字面清理路径，物理模式下解析所有符号链接
+func (sh *Interp) changeDir(fullPath string, physical bool) error {
+	newDir := filepath.Clean(fullPath)
+	if physical {
+		resolved, err := filepath.EvalSymlinks(newDir)
+		if err != nil {
+			return err
+		}
+		newDir = resolved
 	}
-	// 获取 echo 之后的参数部分
-	words := realCommand[len(echoPrefix):]
+	sh.exportVar("OLDPWD", sh.dir)
+	sh.dir = newDir
+	sh.exportVar("PWD", newDir)
+	return nil
+}
 
-	writer := io.Writer(os.Stdout)
-	if outputFile != nil {
-		writer = outputFile
-	} else if appendFile != nil {
-		writer = appendFile
+// expandTilde 展开路径开头的 ~ 前缀（到第一个 / 为止），用于补全时处理还没有展开的单词
+func (sh *Interp) expandTilde(path string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+	end := strings.IndexByte(path, '/')
+	if end == -1 {
+		end = len(path)
 	}
+	home, ok := sh.tildeHome(path[1:end])
+	if !ok {
+		return path
+	}
+	return home + path[end:]
+}
 
-	runEchoBuiltin("echo "+words, writer)
-	return true
+// tildeHome 返回 ~ 前缀表示的目录：空前缀是 HOME（HOME 为空时无法展开），
+// 其他前缀是用户名，展开为该用户的主目录，用户不存在时无法展开
+func (sh *Interp) tildeHome(prefix string) (string, bool) {
+	if prefix == "" {
+		home := sh.getVar("HOME")
+		return home, home != ""
+	}
+	u, err := user.Lookup(prefix)
+	if err != nil || u.HomeDir == "" {
+		return "", false
+	}
+	return u.HomeDir, true
 }
 
-// 处理 type 命令
-func HandleType(cmdSlice []string, outputFile *os.File) bool {
-	writer := os.Stdout
-	if outputFile != nil {
-		writer = outputFile
+// 处理 exit 命令，子 Shell 中只结束子 Shell 本身
+// exit [n]，没有参数时退出状态为上一条命令的退出状态
+func runExitBuiltin(sh *Interp, cmdSlice []string, errorWriter io.Writer) int {
+	status := sh.status
+	if len(cmdSlice) > 1 {
+		n, err := strconv.Atoi(cmdSlice[1])
+		if err != nil {
+			fmt.Fprintf(errorWriter, "exit: %s: numeric argument required\n", cmdSlice[1])
+			n = 2
+		}
+		status = n & 0xff
 	}
-	return runTypeBuiltin(cmdSlice, writer)
+	sh.exit(status)
+	return status
 }
 
-// 处理 pwd 命令
-func HandlePwd(outputFile *os.File, errorFile *os.File, appendFile *os.File, errorAppendFile *os.File) bool {
-	writer := os.Stdout
-	if outputFile != nil {
-		writer = outputFile
-	} else if appendFile != nil {
-		writer = appendFile
+// exit 结束当前 Shell，顶层 Shell 会先保存历史记录，EXIT trap 由 RunExitTrap 执行
+func (sh *Interp) exit(status int) {
+	if !sh.subshell {
+		SaveCmdHistoryToEnvFile()
 	}
-	errorWriter := os.Stderr
-	if errorFile != nil {
-		errorWriter = errorFile
-	} else if errorAppendFile != nil {
-		errorWriter = errorAppendFile
+	sh.status = status
+	sh.exited = true
+}
+
+// 处理 pwd 命令，-L（默认）打印逻辑路径，-P 打印解析符号链接后的物理路径
+func runPwdBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	physical := false
+	for _, arg := range cmdSlice[1:] {
+		switch arg {
+		case "-L":
+			physical = false
+		case "-P":
+			physical = true
+		default:
+			fmt.Fprintf(errorWriter, "pwd: %s: invalid option\n", arg)
+			fmt.Fprintln(errorWriter, "pwd: usage: pwd [-LP]")
+			return 2
+		}
 	}
-	wd, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(errorWriter, "Error getting current directory: %v\n", err)
-		return false
+	wd := sh.dir
+	if physical {
+		resolved, err := filepath.EvalSymlinks(wd)
+		if err != nil {
+			fmt.Fprintf(errorWriter, "Error getting current directory: %v\n", err)
+			return 1
+		}
+		wd = resolved
 	}
 	fmt.Fprintln(writer, wd)
-	return true // 返回 true 表示应该 continue
+	return 0
 }
 
-func HandleHistory(actualCmdSlice []string, outputFile *os.File, appendFile *os.File) bool {
-	writer := os.Stdout
-	if outputFile != nil {
-		writer = outputFile
-	} else if appendFile != nil {
-		writer = appendFile
-	}
-	errorWriter := os.Stderr
-
+func runHistoryBuiltin(sh *Interp, actualCmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
 	if len(actualCmdSlice) == 1 {
 		for i, cmd := range HistoryCmdSlice {
 			fmt.Fprintf(writer, "%d  %s\n", i+1, cmd)
@@ -156,36 +257,36 @@ func HandleHistory(actualCmdSlice []string, outputFile *os.File, appendFile *os.
 		historyNumInt, err := strconv.Atoi(historyNum)
 		if err != nil {
 			fmt.Fprintf(errorWriter, "history: %s: invalid number\n", historyNum)
-			return false
+			return 1
 		}
 		for i := len(HistoryCmdSlice) - historyNumInt; i < len(HistoryCmdSlice); i++ {
 			fmt.Fprintf(writer, "%d  %s\n", i+1, HistoryCmdSlice[i])
 		}
 	} else if len(actualCmdSlice) >= 3 && actualCmdSlice[1] == "-r" {
-		filePath := actualCmdSlice[2]
+		filePath := sh.resolvePath(actualCmdSlice[2])
 		data, err := os.ReadFile(filePath)
 		if err != nil {
 			fmt.Fprintf(errorWriter, "history: failed to read %s: %v\n", filePath, err)
-			return false
+			return 1
 		}
 		contents := strings.Split(string(data), "\n")
 		for _, line := range contents {
 			if strings.TrimSpace(line) == "" {
 				continue
 			}
-			HistoryCmdSlice = append(HistoryCmdSlice, line)
+			AddHistory(line)
 		}
 	} else if len(actualCmdSlice) >= 3 && actualCmdSlice[1] == "-w" {
-		filePath := actualCmdSlice[2]
+		filePath := sh.resolvePath(actualCmdSlice[2])
 		contents := strings.Join(HistoryCmdSlice, "\n")
 		contents += "\n" // 加上尾部换行符
 		os.WriteFile(filePath, []byte(contents), 0644)
 	} else if len(actualCmdSlice) >= 3 && actualCmdSlice[1] == "-a" {
-		filePath := actualCmdSlice[2]
+		filePath := sh.resolvePath(actualCmdSlice[2])
 		appendFile, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
 			fmt.Fprintf(errorWriter, "history: failed to open %s: %v\n", filePath, err)
-			return false
+			return 1
 		}
 		defer appendFile.Close()
 
@@ -198,47 +299,125 @@ func HandleHistory(actualCmdSlice []string, outputFile *os.File, appendFile *os.
 			contents := strings.Join(newEntries, "\n") + "\n"
 			if _, err = appendFile.Write([]byte(contents)); err != nil {
 				fmt.Fprintf(errorWriter, "history: failed to write %s: %v\n", filePath, err)
-				return false
+				return 1
 			}
 			lastHistoryWrittenIndex = len(HistoryCmdSlice)
 		}
 	} else {
 		fmt.Fprintln(errorWriter, "history: invalid usage")
-		return false
+		return 1
 	}
-	return true
+	return 0
 }
 
 // 处理外部命令
-func HandleExternalCommand(commandName string, cmdSlice []string, outputFile *os.File,
-	errorFile *os.File, appendFile *os.File, errorAppendFile *os.File) {
-	if fullPath, found := utils.FindExecutable(commandName); found {
-		// 执行外部程序，正确传递参数
-		cmd := exec.Command(fullPath, cmdSlice[1:]...)
-		// 设置标准输出：如果有重定向文件，使用文件；否则使用标准输出
-		if outputFile != nil {
-			cmd.Stdout = outputFile
-		} else if appendFile != nil {
-			cmd.Stdout = appendFile
-		} else {
-			cmd.Stdout = os.Stdout
-		}
-		// 设置标准错误：如果有重定向文件，使用文件；否则使用标准错误
-		if errorFile != nil {
-			cmd.Stderr = errorFile
-		} else if errorAppendFile != nil {
-			cmd.Stderr = errorAppendFile
-		} else {
-			cmd.Stderr = os.Stderr
-		}
-
-		// 设置进程属性，使外部程序看到的 argv[0] 是命令名而不是完整路径(fullPath)
-		cmd.Args = append([]string{commandName}, cmdSlice[1:]...)
-
-		// 执行命令，错误信息由命令本身输出到 stderr 或 errorFile
-		// 不需要额外打印错误信息
-		_ = cmd.Run()
-	} else {
-		fmt.Printf("%s: command not found\n", commandName)
+func runExternalCommand(sh *Interp, cmdInfo pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	fullPath, err := sh.lookupCommand(cmdInfo.args[0])
+	if err != nil {
+		return sh.commandFailed(cmdInfo, err, stdin, stdout, stderr)
+	}
+	// 执行命令，错误信息由命令本身输出到 stderr 或重定向文件
+	// 不需要额外打印错误信息；没有 #! 行的脚本由 Shell 解释执行
+	err = sh.newExternalCmd(fullPath, cmdInfo, stdin, stdout, stderr).Run()
+	if isExecFormatError(err) {
+		return sh.runScript(fullPath, cmdInfo, stdin, stdout, stderr)
+	}
+	return exitStatus(err)
+}
+
+// commandFailed 输出外部命令无法执行的原因，返回对应的退出状态
+// 找不到命令或文件时为 127，找到了但无法执行（没有执行权限、是目录）时为 126
+// 找不到命令时，如果定义了 command_not_found_handle 函数，在子 Shell 中以命令和参数调用它，
+// 它的退出状态作为命令的退出状态；否则输出与命令名相近的命令作为建议
+func (sh *Interp) commandFailed(cmd pipelineCommand, err error, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	name := cmd.args[0]
+	if errors.Is(err, utils.ErrNotFound) {
+		if body, ok := sh.funcs["command_not_found_handle"]; ok {
+			sub := sh.Clone()
+			// 处理函数中再次找不到命令时不递归调用
+			delete(sub.funcs, "command_not_found_handle")
+			return sub.callFunction(body, append([]string{"command_not_found_handle"}, cmd.args...), stdin, stdout, stderr)
+		}
+		fmt.Fprintf(stderr, "%s: command not found\n", name)
+		sh.suggestCommands(name, stderr)
+		return 127
+	}
+	fmt.Fprintf(stderr, "%s: %v\n", name, err)
+	if errors.Is(err, utils.ErrNoSuchFile) {
+		return 127
+	}
+	return 126
+}
+
+// maxSuggestions 是找不到命令时最多给出的建议数量
+const maxSuggestions = 3
+
+// suggestCommands 在命令 Trie（内置命令和 PATH 中的可执行文件）中查找与 name 编辑距离最近的命令
+// 较短的命令名只允许 1 个字符的差别，避免给出不相关的建议
+func (sh *Interp) suggestCommands(name string, stderr io.Writer) {
+	if strings.Contains(name, "/") {
+		return
+	}
+	maxDistance := 1
+	if len([]rune(name)) > 4 {
+		maxDistance = 2
+	}
+	suggestions := sh.completeCommandTrie().FindSimilar(name, maxDistance)
+	if len(suggestions) == 0 {
+		return
+	}
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	fmt.Fprintln(stderr, "Did you mean:")
+	for _, suggestion := range suggestions {
+		fmt.Fprintf(stderr, "  %s\n", suggestion)
+	}
+}
+
+// newExternalCmd 创建外部程序的 exec.Cmd，工作目录和环境变量取自解释器状态
+func (sh *Interp) newExternalCmd(fullPath string, cmdInfo pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) *exec.Cmd {
+	// 执行外部程序，正确传递参数
+	cmd := exec.Command(fullPath, cmdInfo.args[1:]...)
+	// 设置进程属性，使外部程序看到的 argv[0] 是命令名而不是完整路径(fullPath)
+	cmd.Args = append([]string{cmdInfo.args[0]}, cmdInfo.args[1:]...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Dir = sh.dir
+	cmd.Env = sh.environ(cmdInfo.assigns)
+	cmd.ExtraFiles = extraFiles(cmdInfo.fds)
+	return cmd
+}
+
+// extraFiles 将 3 及以上的描述符转换为 exec.Cmd.ExtraFiles，第 i 项对应描述符 3+i
+func extraFiles(fds map[int]*os.File) []*os.File {
+	var files []*os.File
+	for fd, f := range fds {
+		if fd < 3 {
+			continue
+		}
+		for len(files) <= fd-3 {
+			files = append(files, nil)
+		}
+		files[fd-3] = f
+	}
+	return files
+}
+
+// exitStatus 将进程的错误转换为退出状态，被信号结束的进程为 128 加信号编号
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() >= 0 {
+			return exitErr.ExitCode()
+		}
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
 	}
+	return 126
 }
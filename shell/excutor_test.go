@@ -0,0 +1,42 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// cd 和 pushd 按 bash 的格式报告目标目录的错误
+func TestCDErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sh := NewInterp()
+	sh.dir = dir
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"cd", "missing"}, "cd: missing: No such file or directory\n"},
+		{[]string{"cd", "file"}, "cd: file: Not a directory\n"},
+		{[]string{"cd", "file/sub"}, "cd: file/sub: Not a directory\n"},
+		{[]string{"pushd", "missing"}, "pushd: missing: No such file or directory\n"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		var status int
+		if tt.args[0] == "cd" {
+			status = runCDBuiltin(sh, tt.args, &stdout, &stderr)
+		} else {
+			status = runPushdBuiltin(sh, tt.args, &stdout, &stderr)
+		}
+		if status != 1 || stderr.String() != tt.want {
+			t.Errorf("%q: status %d, stderr %q; want 1, %q", tt.args, status, stderr.String(), tt.want)
+		}
+	}
+	if sh.dir != dir {
+		t.Errorf("directory changed to %s", sh.dir)
+	}
+}
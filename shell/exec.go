@@ -0,0 +1,111 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go_shell/utils"
+)
+
+// 处理 exec 命令：用指定的程序替换当前 Shell 进程，在子 Shell 或管道中只结束这个子 Shell
+// 命令的重定向已经打开，新程序继承它们以及 Shell 描述符表中 3 及以上的描述符
+// 不带命令的 exec 在 runSimple 中由 applyRedirects 处理
+func runExecBuiltin(sh *Interp, cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	args := cmd.args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 0
+	}
+	fullPath, err := sh.lookupCommand(args[0])
+	if errors.Is(err, utils.ErrNotFound) || errors.Is(err, utils.ErrNoSuchFile) {
+		fmt.Fprintf(stderr, "exec: %s: not found\n", args[0])
+		return 127
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "exec: %s: cannot execute: %v\n", args[0], err)
+		return 126
+	}
+
+	if sh.subshell {
+		// 子 Shell 和管道中的一段运行在 Shell 进程内，替换进程会结束整个 Shell；
+		// 改为作为子进程执行命令，然后以它的退出状态结束这个子 Shell
+		cmd.args = args
+		status := runExternalCommand(sh, cmd, stdin, stdout, stderr)
+		sh.exit(status)
+		return status
+	}
+
+	files := map[int]*os.File{}
+	for fd, f := range cmd.fds {
+		files[fd] = f
+	}
+	for fd, v := range map[int]any{0: stdin, 1: stdout, 2: stderr} {
+		f, ok := v.(*os.File)
+		if !ok {
+			// 标准输入输出不是文件（例如被关闭）时，改为 /dev/null
+			if f, _ = os.OpenFile(os.DevNull, os.O_RDWR, 0); f == nil {
+				continue
+			}
+		}
+		files[fd] = f
+	}
+
+	// 新程序替换 Shell 之后不会再回到这里，先保存历史记录
+	SaveCmdHistoryToEnvFile()
+	err = execProcess(fullPath, args, sh.environ(cmd.assigns), sh.dir, files)
+	if isExecFormatError(err) {
+		// 没有 #! 行的脚本由 Shell 解释执行，结束后 Shell 以脚本的退出状态退出
+		cmd.args = args
+		status := sh.runScript(fullPath, cmd, stdin, stdout, stderr)
+		sh.exit(status)
+		return status
+	}
+	fmt.Fprintf(stderr, "exec: %s: %v\n", args[0], err)
+	return 126
+}
+
+// applyRedirects 把重定向应用到 Shell 自身的描述符表，例如 exec 3>log 2>&1
+// 之后执行的内置命令和外部程序都会继承新的描述符；不再使用的文件会被关闭
+func (sh *Interp) applyRedirects(spec redirectSpec, stderr io.Writer) int {
+	stdin, stdout, shellStderr := sh.stdio()
+	cio, _, err := sh.openRedirects(spec, stdin, stdout, shellStderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	fds := make(map[int]*os.File, len(cio.extra)+3)
+	for fd, f := range cio.extra {
+		fds[fd] = f
+	}
+	for fd, v := range map[int]any{0: cio.stdin, 1: cio.stdout, 2: cio.stderr} {
+		f, ok := v.(*os.File)
+		if !ok {
+			// 关闭标准输入输出时改为 /dev/null，保证描述符表中始终有 0、1、2
+			if f, err = os.OpenFile(os.DevNull, os.O_RDWR, 0); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+		}
+		fds[fd] = f
+	}
+
+	// 子 Shell 的描述符表是父 Shell 的副本，其中的文件仍可能被父 Shell 使用，不能关闭
+	if !sh.subshell {
+		inUse := make(map[*os.File]bool, len(fds))
+		for _, f := range fds {
+			inUse[f] = true
+		}
+		for _, f := range sh.fds {
+			if !inUse[f] && f != os.Stdin && f != os.Stdout && f != os.Stderr {
+				f.Close()
+			}
+		}
+	}
+	sh.fds = fds
+	return 0
+}
@@ -0,0 +1,10 @@
+//go:build unix && !linux
+
+package shell
+
+import "syscall"
+
+// dupFd 将 oldFd 复制到 newFd
+func dupFd(oldFd int, newFd int) error {
+	return syscall.Dup2(oldFd, newFd)
+}
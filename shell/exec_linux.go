@@ -0,0 +1,8 @@
+package shell
+
+import "syscall"
+
+// dupFd 将 oldFd 复制到 newFd，部分 Linux 架构没有 dup2，使用 dup3
+func dupFd(oldFd int, newFd int) error {
+	return syscall.Dup3(oldFd, newFd, 0)
+}
@@ -0,0 +1,20 @@
+package shell
+
+import "testing"
+
+// exec 在子 Shell 和管道中只结束这个子 Shell，之后的命令照常执行
+func TestExecInSubshell(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"(exec true); echo ok", "ok\n"},
+		{"echo x | exec cat; echo ok", "x\nok\n"},
+		{"(exec false); echo $?", "1\n"},
+	}
+	for _, tt := range tests {
+		if got := runTest(t, NewInterp(), tt.script); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+//go:build unix
+
+package shell
+
+import (
+	"os"
+	"slices"
+	"syscall"
+)
+
+// execProcess 通过 execve 用新程序替换当前进程
+// files 中的文件被复制到对应编号的描述符上，复制得到的描述符没有 close-on-exec 标志
+// 重定向之间可能互相引用（例如 3>&1 >log 中 3 的来源是原来的 1），
+// 所以先把所有来源复制到不与目标冲突的临时描述符，再按编号顺序复制到目标上
+// execve 失败时 Shell 继续运行，返回之前恢复原来的描述符和工作目录
+func execProcess(path string, args []string, env []string, dir string, files map[int]*os.File) error {
+	targets := make([]int, 0, len(files))
+	for fd := range files {
+		targets = append(targets, fd)
+	}
+	slices.Sort(targets)
+	lowest := 10
+	if len(targets) > 0 {
+		lowest = max(lowest, targets[len(targets)-1]+1)
+	}
+
+	// 保存目标描述符原来的内容，原来没有打开的记录为 -1，恢复时关闭
+	saved := make(map[int]int, len(targets))
+	defer func() {
+		for fd, orig := range saved {
+			if orig == -1 {
+				syscall.Close(fd)
+				continue
+			}
+			dupFd(orig, fd)
+			syscall.Close(orig)
+		}
+	}()
+	for _, fd := range targets {
+		orig, err := dupCloexec(fd, lowest)
+		if err == syscall.EBADF {
+			orig = -1
+		} else if err != nil {
+			return err
+		}
+		saved[fd] = orig
+	}
+
+	temps := make(map[int]int, len(files))
+	defer func() {
+		for _, tmp := range temps {
+			syscall.Close(tmp)
+		}
+	}()
+	for _, fd := range targets {
+		tmp, err := dupCloexec(int(files[fd].Fd()), lowest)
+		if err != nil {
+			return err
+		}
+		temps[fd] = tmp
+	}
+	for _, fd := range targets {
+		if err := dupFd(temps[fd], fd); err != nil {
+			return err
+		}
+	}
+
+	// 解释器不调用 os.Chdir，替换进程之前才切换到 Shell 的工作目录，execve 失败时切换回来
+	cwd, err := os.Open(".")
+	if err != nil {
+		return err
+	}
+	defer cwd.Close()
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	err = syscall.Exec(path, args, env)
+	syscall.Fchdir(int(cwd.Fd()))
+	return err
+}
+
+// dupCloexec 把 fd 复制到不小于 lowest 的最小空闲描述符，新描述符带有 close-on-exec 标志
+func dupCloexec(fd int, lowest int) (int, error) {
+	r, _, errno := syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_DUPFD_CLOEXEC, uintptr(lowest))
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(r), nil
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// execve 失败时（这里是 #! 指定的解释器不存在）Shell 继续运行，
+// 标准输出和进程的工作目录恢复为 exec 之前的状态
+func TestExecFailureRestoresState(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad"), []byte("#!/nonexistent/interpreter\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var before syscall.Stat_t
+	if err := syscall.Fstat(1, &before); err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+
+	sh := NewInterp()
+	sh.dir = dir
+	t.Setenv("HISTFILE", "")
+	if got := runTest(t, sh, "exec ./bad > out.txt; echo $?"); got != "126\n" {
+		t.Errorf("status = %q, want %q", got, "126\n")
+	}
+
+	var after syscall.Stat_t
+	if err := syscall.Fstat(1, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Dev != before.Dev || after.Ino != before.Ino {
+		t.Error("fd 1 still points to the redirected file")
+	}
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("process directory changed to %s", now)
+	}
+}
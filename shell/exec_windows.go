@@ -0,0 +1,20 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+)
+
+// execProcess 在 Windows 上无法替换当前进程，改为运行程序并以它的退出状态结束 Shell
+func execProcess(path string, args []string, env []string, dir string, files map[int]*os.File) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Args = args
+	cmd.Env = env
+	cmd.Dir = dir
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = files[0], files[1], files[2]
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(exitStatus(cmd.Wait()))
+	return nil
+}
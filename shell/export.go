@@ -0,0 +1,71 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// 处理 export 命令：export NAME[=value] ... 设置变量并导出给子进程，
+// export -n NAME 取消导出，不带参数或 export -p 以可重新执行的形式列出导出的变量
+func runExportBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	var unexport bool
+	args := cmdSlice[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'n':
+				unexport = true
+			case 'p':
+			default:
+				fmt.Fprintf(errorWriter, "export: -%c: invalid option\n", flag)
+				fmt.Fprintln(errorWriter, "export: usage: export [-n] [name[=value] ...] or export -p")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		names := make([]string, 0, len(sh.exported))
+		for name := range sh.exported {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value, ok := sh.vars[name]; ok {
+				fmt.Fprintf(writer, "export %s=%s\n", name, shellQuote(value))
+			} else {
+				fmt.Fprintf(writer, "export %s\n", name)
+			}
+		}
+		return 0
+	}
+
+	status := 0
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !isValidName(name) {
+			fmt.Fprintf(errorWriter, "export: `%s': not a valid identifier\n", arg)
+			status = 1
+			continue
+		}
+		switch {
+		case unexport:
+			if hasValue {
+				sh.setVar(name, value)
+			}
+			delete(sh.exported, name)
+		case hasValue:
+			sh.exportVar(name, value)
+		default:
+			sh.exported[name] = true
+		}
+	}
+	return status
+}
@@ -0,0 +1,26 @@
+package shell
+
+import "testing"
+
+// export NAME=value 设置变量并传递给子进程，export -n 取消导出
+func TestExportAssignment(t *testing.T) {
+	sh := NewInterp()
+	got := runTest(t, sh, `export GOSH_TEST_VAR='a b'; echo "$GOSH_TEST_VAR"; sh -c 'echo "$GOSH_TEST_VAR"'; export -n GOSH_TEST_VAR; sh -c 'echo "[$GOSH_TEST_VAR]"'`)
+	if want := "a b\na b\n[]\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := runTest(t, sh, "export 1bad=x; echo $?"); got != "1\n" {
+		t.Errorf("invalid name: got %q, want %q", got, "1\n")
+	}
+}
+
+// 不带参数的 export 按名称排序，以可以重新执行的形式列出导出的变量
+func TestExportList(t *testing.T) {
+	sh := NewInterp()
+	clear(sh.exported)
+	got := runTest(t, sh, "B='x y'; export B; export A=1 C; export; export -p")
+	want := "export A=1\nexport B='x y'\nexport C\n"
+	if got != want+want {
+		t.Errorf("got %q, want %q", got, want+want)
+	}
+}
@@ -0,0 +1,94 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// callFunction 执行函数，args[0] 为函数名，其余参数作为位置参数传入
+func (sh *Interp) callFunction(body *groupNode, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	savedArgs := sh.args
+	sh.args = args[1:]
+	sh.funcDepth++
+	defer func() {
+		sh.args = savedArgs
+		sh.funcDepth--
+		sh.returning = false
+	}()
+	status := sh.runGroup(body, stdin, stdout, stderr)
+	sh.runReturnTrap(status, stdin, stdout, stderr)
+	return status
+}
+
+// 处理 return 命令，结束当前函数或 source 的脚本
+func runReturnBuiltin(sh *Interp, cmdSlice []string, errorWriter io.Writer) int {
+	if sh.funcDepth == 0 {
+		fmt.Fprintln(errorWriter, "return: can only `return' from a function or sourced script")
+		return 1
+	}
+	status := sh.status
+	if len(cmdSlice) > 1 {
+		n, err := strconv.Atoi(cmdSlice[1])
+		if err != nil {
+			fmt.Fprintf(errorWriter, "return: %s: numeric argument required\n", cmdSlice[1])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	sh.returning = true
+	return status
+}
+
+// 处理 source 和 . 命令：在当前 Shell 中执行文件中的命令，额外参数作为位置参数
+func runSourceBuiltin(sh *Interp, cmdSlice []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(cmdSlice) < 2 {
+		fmt.Fprintf(stderr, "%s: filename argument required\n", cmdSlice[0])
+		fmt.Fprintf(stderr, "%s: usage: %s filename [arguments]\n", cmdSlice[0], cmdSlice[0])
+		return 2
+	}
+	return sh.sourceFile(cmdSlice[1], cmdSlice[2:], stdin, stdout, stderr)
+}
+
+// sourceFile 读取并执行脚本文件，args 为空时沿用当前的位置参数
+func (sh *Interp) sourceFile(path string, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	data, err := os.ReadFile(sh.resolvePath(path))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: No such file or directory\n", path)
+		return 1
+	}
+	list, err := parseList(sh.expandAliases(string(data)))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		return 2
+	}
+
+	savedArgs := sh.args
+	if len(args) > 0 {
+		sh.args = args
+	}
+	sh.funcDepth++
+	defer func() {
+		if len(args) > 0 {
+			sh.args = savedArgs
+		}
+		sh.funcDepth--
+		sh.returning = false
+	}()
+	status := sh.runList(list, stdin, stdout, stderr)
+	sh.runReturnTrap(status, stdin, stdout, stderr)
+	return status
+}
+
+// runReturnTrap 在函数或 source 结束时执行 RETURN trap，trap 中的 $? 为返回状态
+func (sh *Interp) runReturnTrap(status int, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	if sh.traps["RETURN"] == "" || sh.exited {
+		return
+	}
+	returning := sh.returning
+	sh.returning = false
+	sh.status = status
+	sh.runConditionTrap("RETURN", stdin, stdout, stderr)
+	sh.returning = returning
+}
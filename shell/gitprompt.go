@@ -0,0 +1,123 @@
+package shell
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go_shell/utils"
+)
+
+// 计算领先/落后提交数时最多遍历的提交数，避免在历史很长的仓库中拖慢提示符
+const gitAheadBehindLimit = 2000
+
+// gitPromptEntry 缓存某个目录对应的仓库信息
+// 分支、暂存区和领先/落后等信息只在 HEAD、index、refs、config 的修改时间变化时重新计算，
+// 工作区是否有修改需要每次检查，但只比较文件的大小和修改时间，需要计算的哈希缓存在 index 中
+type gitPromptEntry struct {
+	repo     *utils.GitRepo // 为空表示该目录不在仓库中
+	dirMtime time.Time      // 不在仓库中时，目录本身的修改时间（git init 会改变它）
+	stamps   []time.Time
+	status   utils.GitStatus
+	index    *utils.GitIndex // 读取失败时为空
+}
+
+var gitPromptCache = struct {
+	sync.Mutex
+	entries map[string]*gitPromptEntry
+}{entries: make(map[string]*gitPromptEntry)}
+
+// gitPromptSegment 返回提示符中 \g 展开的内容，不在仓库中时为空
+// 格式为 分支名（分离 HEAD 时为括号中的短提交 ID），后跟标记：
+// * 有未暂存的修改，+ 有已暂存的修改，↑N 领先上游 N 个提交，↓N 落后上游 N 个提交
+func (sh *Interp) gitPromptSegment() string {
+	gitPromptCache.Lock()
+	defer gitPromptCache.Unlock()
+
+	entry := gitPromptCache.entries[sh.dir]
+	if entry == nil || (entry.repo == nil && !modTime(sh.dir).Equal(entry.dirMtime)) {
+		entry = &gitPromptEntry{}
+		if repo, ok := utils.FindGitRepo(sh.dir); ok {
+			entry.repo = repo
+		} else {
+			entry.dirMtime = modTime(sh.dir)
+		}
+		gitPromptCache.entries[sh.dir] = entry
+	}
+	if entry.repo == nil {
+		return ""
+	}
+
+	stamps := fileStamps(entry.repo.StampFiles(entry.status.Branch))
+	if !slices.Equal(stamps, entry.stamps) {
+		if !entry.refresh() {
+			return ""
+		}
+		// 分支可能已经切换，需要用新的分支重新记录时间戳
+		entry.stamps = fileStamps(entry.repo.StampFiles(entry.status.Branch))
+	}
+	entry.status.Unstaged = entry.index != nil && entry.repo.HasUnstagedChanges(entry.index)
+	return formatGitStatus(entry.status)
+}
+
+// refresh 重新读取 HEAD、index 和上游信息
+func (e *gitPromptEntry) refresh() bool {
+	branch, head, err := e.repo.Head()
+	if err != nil {
+		return false
+	}
+	status := utils.GitStatus{Branch: branch, Head: head}
+	index, err := e.repo.ReadIndex()
+	if err == nil {
+		status.Staged, _ = e.repo.HasStagedChanges(index, head)
+	}
+	if branch != "" {
+		status.Ahead, status.Behind, _ = e.repo.AheadBehind(branch, head, gitAheadBehindLimit)
+	}
+	e.status = status
+	e.index = index
+	return true
+}
+
+func formatGitStatus(status utils.GitStatus) string {
+	segment := status.Branch
+	if segment == "" {
+		short := status.Head
+		if len(short) > 7 {
+			short = short[:7]
+		}
+		segment = "(" + short + ")"
+	}
+	if status.Unstaged {
+		segment += "*"
+	}
+	if status.Staged {
+		segment += "+"
+	}
+	if status.Ahead > 0 {
+		segment += "↑" + strconv.Itoa(status.Ahead)
+	}
+	if status.Behind > 0 {
+		segment += "↓" + strconv.Itoa(status.Behind)
+	}
+	return segment
+}
+
+// fileStamps 返回一组文件的修改时间，不存在的文件记为零值
+func fileStamps(files []string) []time.Time {
+	stamps := make([]time.Time, len(files))
+	for i, file := range files {
+		stamps[i] = modTime(file)
+	}
+	return stamps
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
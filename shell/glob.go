@@ -0,0 +1,95 @@
+package shell
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// expandWords 拆分变量展开后的命令文本，并对含有引号外通配符的单词做文件名展开
+// 命令名之前的变量赋值不做文件名展开；set -f 时完全禁用文件名展开
+// 返回的 globbed 表示是否有单词被替换成了匹配的文件名
+func (sh *Interp) expandWords(expanded string) (args []string, globbed bool) {
+	assigning := true
+	for _, word := range parseWords(expanded) {
+		if assigning && isAssignment(word.text) {
+			args = append(args, word.text)
+			continue
+		}
+		assigning = false
+		if !word.glob || sh.options["noglob"] {
+			args = append(args, word.text)
+			continue
+		}
+		matches := sh.glob(word.pattern)
+		if len(matches) == 0 {
+			// 没有匹配时保留原样，nullglob 时删除这个单词
+			if sh.options["nullglob"] {
+				globbed = true
+			} else {
+				args = append(args, word.text)
+			}
+			continue
+		}
+		args = append(args, matches...)
+		globbed = true
+	}
+	return args, globbed
+}
+
+// glob 返回与模式匹配的文件名，相对路径相对于解释器的工作目录
+// 与 bash 一样，除非开启 dotglob，否则模式中不以 . 开头的部分不匹配隐藏文件
+func (sh *Interp) glob(pattern string) []string {
+	full := pattern
+	if !filepath.IsAbs(pattern) {
+		full = filepath.Join(escapeGlob(sh.dir), pattern)
+	}
+	matches, err := filepath.Glob(full)
+	if err != nil {
+		return nil
+	}
+
+	patternParts := strings.Split(filepath.Clean(pattern), "/")
+	var result []string
+	for _, match := range matches {
+		if !filepath.IsAbs(pattern) {
+			rel, err := filepath.Rel(sh.dir, match)
+			if err != nil {
+				continue
+			}
+			match = rel
+		}
+		if !sh.options["dotglob"] && hiddenMatch(strings.Split(filepath.Clean(match), "/"), patternParts) {
+			continue
+		}
+		if strings.HasPrefix(pattern, "./") {
+			match = "./" + match
+		}
+		result = append(result, match)
+	}
+	return result
+}
+
+// hiddenMatch 判断匹配结果中是否有以 . 开头的部分是由不以 . 开头的模式匹配到的
+func hiddenMatch(parts []string, patternParts []string) bool {
+	if len(parts) != len(patternParts) {
+		return false
+	}
+	for i, part := range parts {
+		if strings.HasPrefix(part, ".") && !strings.HasPrefix(patternParts[i], ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeGlob 转义字符串中的通配符，使其在模式中按字面匹配
+func escapeGlob(s string) string {
+	var result strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("*?[\\", s[i]) != -1 {
+			result.WriteByte('\\')
+		}
+		result.WriteByte(s[i])
+	}
+	return result.String()
+}
@@ -0,0 +1,57 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// 交互式 Shell 启动时读取的配置文件，位于 HOME 目录下
+const rcFileName = ".goshellrc"
+
+// SourceRCFile 启动时执行 ~/.goshellrc，文件不存在时忽略
+// 可以在其中定义 precmd、preexec 函数以及设置 PS1、PROMPT_COMMAND 等变量
+func (sh *Interp) SourceRCFile() {
+	home := sh.getVar("HOME")
+	if home == "" {
+		return
+	}
+	rcFile := filepath.Join(home, rcFileName)
+	if _, err := os.Stat(rcFile); err != nil {
+		return
+	}
+	stdin, stdout, stderr := sh.stdio()
+	sh.sourceFile(rcFile, nil, stdin, stdout, stderr)
+}
+
+// RunPromptHooks 在绘制主提示符之前执行 PROMPT_COMMAND 和 precmd 函数
+// 钩子不会改变 $?，提示符中的 \? 仍然是用户上一条命令的退出状态
+// 返回 true 表示钩子中执行了 exit
+func (sh *Interp) RunPromptHooks() bool {
+	// 等待输入期间收到的信号在绘制提示符之前处理
+	sh.runPendingTraps()
+	status := sh.status
+	if command := sh.getVar("PROMPT_COMMAND"); command != "" {
+		sh.Run(command)
+	}
+	if body, ok := sh.funcs["precmd"]; ok && !sh.exited {
+		sh.status = status
+		stdin, stdout, stderr := sh.stdio()
+		sh.callFunction(body, []string{"precmd"}, stdin, stdout, stderr)
+	}
+	sh.status = status
+	return sh.exited
+}
+
+// RunPreexec 在执行用户输入的命令之前调用 preexec 函数，整行命令作为 $1 传入
+// 返回 true 表示钩子中执行了 exit
+func (sh *Interp) RunPreexec(line string) bool {
+	body, ok := sh.funcs["preexec"]
+	if !ok {
+		return false
+	}
+	status := sh.status
+	stdin, stdout, stderr := sh.stdio()
+	sh.callFunction(body, []string{"preexec", line}, stdin, stdout, stderr)
+	sh.status = status
+	return sh.exited
+}
@@ -0,0 +1,703 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Interp 保存一个 Shell 解释器的运行状态
+// 内置命令可能在管道的 goroutine 中运行，子 Shell 通过 Clone 获得独立副本，
+// 在其中切换目录或修改变量不会影响父 Shell
+type Interp struct {
+	dir      string                // 当前工作目录
+	dirStack []string              // pushd 保存的目录栈（不含当前目录）
+	vars     map[string]string     // Shell 变量
+	arrays   map[string][]string   // 数组变量，例如 COMPREPLY=(a b)，vars 中保存第一个元素
+	exported map[string]bool       // 需要传递给子进程的变量
+	funcs    map[string]*groupNode // 已定义的函数
+	aliases  map[string]string     // alias 定义的别名
+	name     string                // $0，执行脚本时为脚本名，为空时使用 Shell 的程序名
+	args     []string              // 位置参数 $1 $2 ...
+	status   int                   // 上一条命令的退出状态，即 $?
+	subshell bool                  // 是否为子 Shell
+	exited   bool                  // 是否已执行 exit
+
+	funcDepth int  // 正在执行的函数和 source 的嵌套层数，用于判断 return 是否合法
+	returning bool // 是否已执行 return，需要结束当前函数或 source
+
+	options        map[string]bool // set 和 shopt 设置的选项
+	conditionDepth int             // 大于 0 时命令的失败不触发 errexit，例如 && 和 || 左侧的命令
+	aborting       bool            // 出现展开错误（如 set -u 时的未设置变量），放弃执行本次输入的剩余命令
+	unbound        string          // 最近一次展开中遇到的第一个未设置的变量
+	pipeStatus     []int           // 最近一个管道中每条命令的退出状态，即 PIPESTATUS
+
+	traps   map[string]string // trap 设置的命令，键为不带 SIG 前缀的信号名或 EXIT、ERR、DEBUG、RETURN
+	signals chan os.Signal    // trap 捕获的信号，只有顶层 Shell 接收
+	inTrap  bool              // 是否正在执行 trap 的命令
+
+	fds map[int]*os.File // Shell 自身的文件描述符表，exec 不带命令时修改，命令默认从这里继承
+
+	hashed   map[string]hashEntry // 外部命令的 hash 表，记录在 PATH 中找到的完整路径，修改 PATH 时清空
+	commands *commandIndex        // 内置命令和 PATH 中可执行文件的 Trie，用于补全和找不到命令时给出建议
+
+	completions     map[string]*completionSpec // complete 注册的补全规则，键为命令名
+	completionTrees map[string]*completionTree // 从补全规则文件加载的补全树，键为命令名
+
+	promptLine   string   // 最近一次渲染的提示符（最后一行），绘制右侧提示符和补全菜单时用来计算输入行的宽度
+	rightPrompt  string   // 最近一次渲染的 RPROMPT，由 PromptPainter 绘制在输入行右侧
+	drawnPrompts []string // 读取当前命令期间输出的各个提示符的可见文本，瞬态提示符重绘时使用
+
+	completionMenu *completionMenu // 打开的补全菜单，由 PromptPainter 绘制在输入行下方
+}
+
+// NewInterp 创建顶层解释器，变量从当前进程的环境变量初始化
+func NewInterp() *Interp {
+	sh := &Interp{
+		vars:     make(map[string]string),
+		arrays:   make(map[string][]string),
+		exported: make(map[string]bool),
+		funcs:    make(map[string]*groupNode),
+		aliases:  make(map[string]string),
+		options:  make(map[string]bool),
+		traps:    make(map[string]string),
+		signals:  make(chan os.Signal, 16),
+		fds:      map[int]*os.File{0: os.Stdin, 1: os.Stdout, 2: os.Stderr},
+		hashed:   make(map[string]hashEntry),
+		commands: &commandIndex{},
+
+		completions:     make(map[string]*completionSpec),
+		completionTrees: make(map[string]*completionTree),
+	}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			sh.vars[name] = value
+			sh.exported[name] = true
+		}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting current directory: %v\n", err)
+	}
+	sh.dir = dir
+	sh.exportVar("PWD", dir)
+	return sh
+}
+
+// Clone 复制解释器状态，用于子 Shell 和管道中的内置命令
+// 与 bash 一样，子 Shell 只继承被忽略的信号，其他 trap 被重置
+func (sh *Interp) Clone() *Interp {
+	traps := make(map[string]string)
+	for name, action := range sh.traps {
+		if _, ok := trapSignals[name]; ok && action == "" {
+			traps[name] = action
+		}
+	}
+	return &Interp{
+		dir:      sh.dir,
+		dirStack: slices.Clone(sh.dirStack),
+		vars:     maps.Clone(sh.vars),
+		arrays:   maps.Clone(sh.arrays),
+		exported: maps.Clone(sh.exported),
+		funcs:    maps.Clone(sh.funcs),
+		aliases:  maps.Clone(sh.aliases),
+		name:     sh.name,
+		args:     slices.Clone(sh.args),
+		status:   sh.status,
+		subshell: true,
+
+		funcDepth: sh.funcDepth,
+
+		options:        maps.Clone(sh.options),
+		conditionDepth: sh.conditionDepth,
+		pipeStatus:     slices.Clone(sh.pipeStatus),
+
+		traps:  traps,
+		inTrap: sh.inTrap,
+
+		fds:      maps.Clone(sh.fds),
+		hashed:   maps.Clone(sh.hashed),
+		commands: sh.commands,
+
+		completions:     maps.Clone(sh.completions),
+		completionTrees: sh.completionTrees,
+	}
+}
+
+// Run 展开别名后解析并执行一行输入，返回 true 表示 Shell 应该退出
+func (sh *Interp) Run(line string) bool {
+	stdin, stdout, stderr := sh.stdio()
+	list, err := parseList(sh.expandAliases(line))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		sh.status = 2
+		return false
+	}
+	sh.aborting = false
+	sh.runList(list, stdin, stdout, stderr)
+	return sh.exited
+}
+
+// stdio 返回 Shell 描述符表中的标准输入、输出和错误
+func (sh *Interp) stdio() (io.Reader, io.Writer, io.Writer) {
+	return sh.fds[0], sh.fds[1], sh.fds[2]
+}
+
+// IsIncomplete 判断输入是否还没有结束（例如引号未闭合、组未结束或以 | && || 结尾），
+// 此时应该使用 PS2 提示符继续读取下一行
+func IsIncomplete(input string) bool {
+	_, err := parseList(input)
+	var syntaxErr *syntaxError
+	return errors.As(err, &syntaxErr) && syntaxErr.token == ""
+}
+
+// runList 依次执行命令列表，每条命令之间处理收到的信号
+func (sh *Interp) runList(list *listNode, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	for _, item := range list.items {
+		sh.runPendingTraps()
+		if sh.unwinding() {
+			break
+		}
+		sh.status = sh.runAndOr(item, stdin, stdout, stderr)
+	}
+	sh.runPendingTraps()
+	return sh.status
+}
+
+// runAndOr 执行 && / || 连接的命令，根据上一条的退出状态决定是否继续
+// 只有最后一条命令的失败会触发 ERR trap 和 errexit
+func (sh *Interp) runAndOr(node *andOrNode, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	last := len(node.pipelines) - 1
+	status := 0
+	for i, pipeline := range node.pipelines {
+		if i > 0 {
+			if sh.unwinding() {
+				break
+			}
+			sh.status = status
+			if (node.ops[i-1] == "&&") != (status == 0) {
+				continue
+			}
+		}
+		if i < last {
+			sh.conditionDepth++
+			status = sh.runPipeline(pipeline, stdin, stdout, stderr)
+			sh.conditionDepth--
+			continue
+		}
+		status = sh.runPipeline(pipeline, stdin, stdout, stderr)
+		if status != 0 && sh.conditionDepth == 0 && !sh.unwinding() {
+			sh.status = status
+			sh.runConditionTrap("ERR", stdin, stdout, stderr)
+			if sh.options["errexit"] && !sh.exited {
+				sh.exit(status)
+			}
+		}
+	}
+	return status
+}
+
+// runPipeline 执行管道并记录 PIPESTATUS，开启 pipefail 时返回最后一个失败命令的退出状态
+func (sh *Interp) runPipeline(node *pipelineNode, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	var statuses []int
+	if len(node.stages) > 1 {
+		statuses = executePipeline(sh, node.stages, stdin, stdout, stderr)
+	} else {
+		statuses = []int{sh.runStage(node.stages[0], stdin, stdout, stderr)}
+	}
+	sh.pipeStatus = statuses
+
+	status := statuses[len(statuses)-1]
+	if sh.options["pipefail"] {
+		for _, s := range statuses {
+			if s != 0 {
+				status = s
+			}
+		}
+	}
+	return status
+}
+
+// runStage 在当前 Shell 中执行管道中唯一的一条命令
+func (sh *Interp) runStage(stage *stageNode, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if stage.function != nil {
+		sh.funcs[stage.function.name] = stage.function.body
+		return 0
+	}
+	if stage.group != nil {
+		return sh.runGroup(stage.group, stdin, stdout, stderr)
+	}
+	return sh.runSimple(stage.raw, stdin, stdout, stderr)
+}
+
+// runGroup 执行 ( ... ) 或 { ...; }，子 Shell 在解释器的副本中执行
+func (sh *Interp) runGroup(group *groupNode, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	_, spec := parseRedirectSpec(ParseCommand(sh.expandVariables(sh.expandTildes(group.redirect))))
+	cio, closers, err := sh.openRedirects(spec, stdin, stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer closeFiles(closers)
+	stdin, stdout, stderr = cio.stdin, cio.stdout, cio.stderr
+
+	if group.subshell {
+		sub := sh.Clone()
+		sub.runList(group.body, stdin, stdout, stderr)
+		return sub.runExitTrap(stdin, stdout, stderr)
+	}
+	return sh.runList(group.body, stdin, stdout, stderr)
+}
+
+// runSimple 在当前 Shell 中执行一条简单命令
+func (sh *Interp) runSimple(raw string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if name, elements, ok := parseArrayAssignment(raw); ok {
+		// 括号内的元素可以分多行书写
+		words, _ := sh.expandWords(sh.expandVariables(sh.expandTildes(strings.ReplaceAll(elements, "\n", " "))))
+		sh.setArray(name, words)
+		return 0
+	}
+	cmd, err := sh.parseSimpleCommand(raw)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		sh.aborting = true
+		return 1
+	}
+	sh.runConditionTrap("DEBUG", stdin, stdout, stderr)
+	sh.trace(cmd, stderr)
+	if len(cmd.args) == 0 {
+		// 只有变量赋值的命令，例如 FOO=bar
+		for _, assign := range cmd.assigns {
+			name, value, _ := strings.Cut(assign, "=")
+			sh.setVar(name, value)
+		}
+		return 0
+	}
+
+	if cmd.args[0] == "exec" && len(cmd.args) == 1 {
+		// 不带命令的 exec 把重定向应用到 Shell 自身
+		return sh.applyRedirects(cmd.redirect, stderr)
+	}
+
+	cio, closers, err := sh.openRedirects(cmd.redirect, stdin, stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer closeFiles(closers)
+	stdin, stdout, stderr = cio.stdin, cio.stdout, cio.stderr
+	cmd.fds = cio.extra
+
+	return sh.runCommand(cmd, stdin, stdout, stderr)
+}
+
+// runCommand 按函数、内置命令、外部程序的顺序查找并执行命令
+func (sh *Interp) runCommand(cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(cmd.args) == 0 {
+		return 0
+	}
+	sh.commands.recordUse(cmd.args[0])
+	if body, ok := sh.funcs[cmd.args[0]]; ok {
+		return sh.callFunction(body, cmd.args, stdin, stdout, stderr)
+	}
+	if cmd.isBuiltin {
+		return runBuiltinCommand(sh, cmd, stdin, stdout, stderr)
+	}
+	return runExternalCommand(sh, cmd, stdin, stdout, stderr)
+}
+
+// unwinding 判断是否因为 exit、return 或展开错误需要停止执行后续命令
+func (sh *Interp) unwinding() bool {
+	return sh.exited || sh.returning || sh.aborting
+}
+
+// parseSimpleCommand 展开 ~、变量和文件名，并解析参数、前置赋值和重定向
+// 开启 nounset 时，展开未设置的变量会返回错误
+func (sh *Interp) parseSimpleCommand(raw string) (pipelineCommand, error) {
+	expanded := sh.expandVariables(sh.expandTildes(raw))
+	if sh.unbound != "" && sh.options["nounset"] {
+		return pipelineCommand{}, fmt.Errorf("%s: unbound variable", sh.unbound)
+	}
+	words, globbed := sh.expandWords(expanded)
+	actualCmdSlice, spec := parseRedirectSpec(words)
+
+	// 命令名之前形如 NAME=value 的单词是变量赋值
+	n := 0
+	for n < len(actualCmdSlice) && isAssignment(actualCmdSlice[n]) {
+		n++
+	}
+	cmd := pipelineCommand{
+		args:     actualCmdSlice[n:],
+		raw:      stripRedirects(expanded, spec),
+		assigns:  actualCmdSlice[:n],
+		redirect: spec,
+	}
+	if globbed {
+		// echo 直接处理原始文本，文件名展开后需要用展开结果重新生成
+		cmd.raw = quoteWords(actualCmdSlice)
+	}
+	if len(cmd.args) > 0 {
+		cmd.isBuiltin = isBuiltinCommand(cmd.args[0])
+	}
+	return cmd, nil
+}
+
+// trace 在开启 xtrace 时把即将执行的命令输出到标准错误，前缀为 PS4
+func (sh *Interp) trace(cmd pipelineCommand, stderr io.Writer) {
+	if !sh.options["xtrace"] {
+		return
+	}
+	words := make([]string, 0, len(cmd.assigns)+len(cmd.args))
+	for _, assign := range cmd.assigns {
+		name, value, _ := strings.Cut(assign, "=")
+		words = append(words, name+"="+shellQuote(value))
+	}
+	for _, arg := range cmd.args {
+		words = append(words, shellQuote(arg))
+	}
+	prefix, _ := sh.expandPrompt(sh.promptValue("PS4"))
+	fmt.Fprintf(stderr, "%s%s\n", prefix, strings.Join(words, " "))
+}
+
+// resolvePath 将相对路径解析为相对于解释器工作目录的绝对路径
+func (sh *Interp) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(sh.dir, path)
+}
+
+// getVar 返回变量的值，EPOCHSECONDS 和 EPOCHREALTIME 在读取时动态生成
+func (sh *Interp) getVar(name string) string {
+	switch name {
+	case "EPOCHSECONDS":
+		return strconv.FormatInt(time.Now().Unix(), 10)
+	case "EPOCHREALTIME":
+		now := time.Now()
+		return fmt.Sprintf("%d.%06d", now.Unix(), now.Nanosecond()/1000)
+	}
+	return sh.vars[name]
+}
+
+// positional 返回第 n 个位置参数，$0 为 Shell 名称
+func (sh *Interp) positional(n int) string {
+	if n == 0 {
+		if sh.name != "" {
+			return sh.name
+		}
+		return os.Args[0]
+	}
+	if n > len(sh.args) {
+		return ""
+	}
+	return sh.args[n-1]
+}
+
+// setVar 设置变量，修改 PATH 时清空 hash 表
+func (sh *Interp) setVar(name string, value string) {
+	sh.vars[name] = value
+	delete(sh.arrays, name)
+	if name == "PATH" {
+		clear(sh.hashed)
+	}
+}
+
+// setArray 设置数组变量，变量名单独使用时取第一个元素
+func (sh *Interp) setArray(name string, elements []string) {
+	sh.vars[name] = ""
+	if len(elements) > 0 {
+		sh.vars[name] = elements[0]
+	}
+	sh.arrays[name] = elements
+}
+
+// exportVar 设置变量并标记为导出
+func (sh *Interp) exportVar(name string, value string) {
+	sh.setVar(name, value)
+	sh.exported[name] = true
+}
+
+// environ 生成传递给子进程的环境变量，extra 为命令前置的临时赋值
+func (sh *Interp) environ(extra []string) []string {
+	env := make([]string, 0, len(sh.exported)+len(extra))
+	for name := range sh.exported {
+		// export NAME 标记了还没有值的变量，赋值之后才传递给子进程
+		if value, ok := sh.vars[name]; ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, extra...)
+}
+
+// isAssignment 判断单词是否是 NAME=value 形式的变量赋值
+func isAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	return ok && isValidName(name)
+}
+
+// parseArrayAssignment 判断命令是否是 NAME=(...) 形式的数组赋值，返回变量名和括号内的文本
+func parseArrayAssignment(raw string) (string, string, bool) {
+	name, rest, ok := strings.Cut(raw, "=(")
+	if !ok || !isValidName(name) || !strings.HasSuffix(rest, ")") {
+		return "", "", false
+	}
+	return name, strings.TrimSuffix(rest, ")"), true
+}
+
+// isValidName 判断是否是合法的变量名：字母或下划线开头，后跟字母、数字或下划线
+func isValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, ch := range name {
+		if ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (i > 0 && ch >= '0' && ch <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// getArray 返回数组变量的元素，普通变量视为只有一个元素的数组
+// DIRSTACK 由目录栈动态生成，PIPESTATUS 为最近一个管道中每条命令的退出状态
+func (sh *Interp) getArray(name string) []string {
+	if name == "PIPESTATUS" {
+		statuses := make([]string, len(sh.pipeStatus))
+		for i, status := range sh.pipeStatus {
+			statuses[i] = strconv.Itoa(status)
+		}
+		return statuses
+	}
+	if name == "DIRSTACK" {
+		stack := sh.fullDirStack()
+		for i, dir := range stack {
+			stack[i] = sh.abbreviateHome(dir)
+		}
+		return stack
+	}
+	if elements, ok := sh.arrays[name]; ok {
+		return elements
+	}
+	if value, ok := sh.vars[name]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+// expandTildes 展开引号之外、位于单词开头（包括重定向符号之后）的 ~，以及形如 NAME=value 的单词中 = 和 : 之后的 ~
+// ~ 之后到 / 为止的部分（赋值中到 : 为止）是 ~ 前缀，无法展开时保留原样；
+// 展开结果在需要时加上单引号，其中的空格、$ 和通配符不会被再次拆分或展开
+func (sh *Interp) expandTildes(raw string) string {
+	if !strings.Contains(raw, "~") {
+		return raw
+	}
+	var result strings.Builder
+	inSingleQuotes := false
+	inDoubleQuotes := false
+	wordStart := 0 // 当前单词在 result 中的开始位置
+
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		quoted := inSingleQuotes || inDoubleQuotes
+		switch {
+		case ch == '\'' && !inDoubleQuotes:
+			inSingleQuotes = !inSingleQuotes
+		case ch == '"' && !inSingleQuotes:
+			inDoubleQuotes = !inDoubleQuotes
+		case ch == '\\' && !inSingleQuotes && i+1 < len(raw):
+			result.WriteByte(ch)
+			i++
+			ch = raw[i]
+		case !quoted && strings.IndexByte(" \t\n<>", ch) != -1:
+			result.WriteByte(ch)
+			wordStart = result.Len()
+			continue
+		case ch == '~' && !quoted:
+			word := result.String()[wordStart:]
+			assignment := isAssignment(word)
+			if word == "" || assignment && (strings.HasSuffix(word, ":") || strings.Index(word, "=") == len(word)-1) {
+				end := i + 1
+				for end < len(raw) && strings.IndexByte("/ \t\n<>", raw[end]) == -1 && !(assignment && raw[end] == ':') {
+					end++
+				}
+				prefix := raw[i+1 : end]
+				if !strings.ContainsAny(prefix, "'\"\\$`") {
+					if home, ok := sh.tildeHome(prefix); ok {
+						result.WriteString(shellQuote(home))
+						i = end - 1
+						continue
+					}
+				}
+			}
+		}
+		result.WriteByte(ch)
+	}
+	return result.String()
+}
+
+// expandVariables 展开单引号之外的 $NAME、${NAME}、${NAME[i]}、位置参数、$? 和 $$
+func (sh *Interp) expandVariables(raw string) string {
+	sh.unbound = ""
+	if !strings.Contains(raw, "$") {
+		return raw
+	}
+	var result strings.Builder
+	inSingleQuotes := false
+	inDoubleQuotes := false
+
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case ch == '\'' && !inDoubleQuotes:
+			inSingleQuotes = !inSingleQuotes
+			result.WriteByte(ch)
+		case ch == '"' && !inSingleQuotes:
+			inDoubleQuotes = !inDoubleQuotes
+			result.WriteByte(ch)
+		case ch == '\\' && !inSingleQuotes && i+1 < len(raw):
+			// \$ 表示字面的 $，其余转义原样保留交给后续解析
+			if raw[i+1] != '$' {
+				result.WriteByte(ch)
+			}
+			result.WriteByte(raw[i+1])
+			i++
+		case ch == '$' && !inSingleQuotes && i+1 < len(raw):
+			value, consumed := sh.expandParameter(raw[i+1:])
+			if consumed == 0 {
+				result.WriteByte(ch)
+				continue
+			}
+			result.WriteString(value)
+			i += consumed
+		default:
+			result.WriteByte(ch)
+		}
+	}
+	return result.String()
+}
+
+// expandParameter 展开 $ 之后的参数，返回展开后的值以及消耗的字节数
+func (sh *Interp) expandParameter(s string) (string, int) {
+	switch s[0] {
+	case '?':
+		return strconv.Itoa(sh.status), 1
+	case '$':
+		return strconv.Itoa(os.Getpid()), 1
+	case '-':
+		return sh.optionFlags(), 1
+	case '#':
+		return strconv.Itoa(len(sh.args)), 1
+	case '@', '*':
+		return strings.Join(sh.args, " "), 1
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		n := int(s[0] - '0')
+		if n > len(sh.args) {
+			sh.markUnbound(s[:1])
+		}
+		return sh.positional(n), 1
+	case '{':
+		end := strings.IndexByte(s, '}')
+		if end == -1 {
+			return "", 0
+		}
+		value, ok := sh.expandBraced(s[1:end])
+		if !ok {
+			return "", 0
+		}
+		return value, end + 1
+	}
+	n := 0
+	for n < len(s) && isValidName(s[:n+1]) {
+		n++
+	}
+	if n == 0 {
+		return "", 0
+	}
+	if !sh.isSet(s[:n]) {
+		sh.markUnbound(s[:n])
+	}
+	return sh.getVar(s[:n]), n
+}
+
+// isSet 判断变量是否已设置，包括动态生成的变量
+func (sh *Interp) isSet(name string) bool {
+	switch name {
+	case "EPOCHSECONDS", "EPOCHREALTIME", "DIRSTACK", "PIPESTATUS":
+		return true
+	}
+	_, ok := sh.vars[name]
+	return ok
+}
+
+// markUnbound 记录展开过程中遇到的第一个未设置的变量，set -u 时由调用者报错
+func (sh *Interp) markUnbound(name string) {
+	if sh.unbound == "" {
+		sh.unbound = name
+	}
+}
+
+// expandBraced 展开 ${...} 中的内容，支持 NAME、NAME[i]、NAME[@]、#NAME[@]
+func (sh *Interp) expandBraced(expr string) (string, bool) {
+	if n, err := strconv.Atoi(expr); err == nil && n >= 0 {
+		if n > len(sh.args) {
+			sh.markUnbound(expr)
+		}
+		return sh.positional(n), true
+	}
+	if isValidName(expr) {
+		if !sh.isSet(expr) {
+			sh.markUnbound(expr)
+		}
+		// 数组名单独出现时取第一个元素
+		if elements := sh.getArray(expr); len(elements) > 0 {
+			return elements[0], true
+		}
+		return "", true
+	}
+
+	length := strings.HasPrefix(expr, "#")
+	if length {
+		expr = expr[1:]
+	}
+	name, subscript, ok := strings.Cut(expr, "[")
+	if !ok || !strings.HasSuffix(subscript, "]") || !isValidName(name) {
+		if length && isValidName(expr) {
+			if !sh.isSet(expr) {
+				sh.markUnbound(expr)
+			}
+			return strconv.Itoa(len(sh.getVar(expr))), true
+		}
+		return "", false
+	}
+	subscript = strings.TrimSuffix(subscript, "]")
+	elements := sh.getArray(name)
+
+	if subscript == "@" || subscript == "*" {
+		if length {
+			return strconv.Itoa(len(elements)), true
+		}
+		return strings.Join(elements, " "), true
+	}
+	index, err := strconv.Atoi(subscript)
+	if err != nil {
+		return "", false
+	}
+	if index < 0 {
+		index += len(elements)
+	}
+	if index < 0 || index >= len(elements) {
+		sh.markUnbound(name + "[" + subscript + "]")
+		return "", true
+	}
+	if length {
+		return strconv.Itoa(len(elements[index])), true
+	}
+	return elements[index], true
+}
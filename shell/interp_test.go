@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"os/user"
+	"strings"
+	"testing"
+)
+
+// runTest 在新的解释器中执行 script，返回标准输出
+// 标准错误是文件而不是缓冲区：管道中的多个外部程序会同时写入标准错误
+func runTest(t *testing.T, sh *Interp, script string) string {
+	t.Helper()
+	list, err := parseList(script)
+	if err != nil {
+		t.Fatalf("parse %q: %v", script, err)
+	}
+	stderr, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+	var stdout bytes.Buffer
+	sh.runList(list, strings.NewReader(""), &stdout, stderr)
+	return stdout.String()
+}
+
+// 引号之外位于单词开头的 ~ 展开为 HOME，包括赋值中 = 和 : 之后以及重定向的目标
+func TestTildeExpansion(t *testing.T) {
+	dir := t.TempDir()
+	sh := NewInterp()
+	sh.setVar("HOME", dir)
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"echo ~ ~/x", dir + " " + dir + "/x\n"},
+		{`echo "~" '~' a~ ~/"b c"`, "~ ~ a~ " + dir + "/b c\n"},
+		{"A=~/a:~/b; echo $A", dir + "/a:" + dir + "/b\n"},
+		{"echo hi >~/out; cat ~/out", "hi\n"},
+		{"cd ~; pwd", dir + "\n"},
+	}
+	for _, tt := range tests {
+		if got := runTest(t, sh, tt.script); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+// ~user 展开为该用户的主目录，用户不存在时保留原样
+func TestTildeUser(t *testing.T) {
+	u, err := user.Current()
+	if err != nil || u.HomeDir == "" {
+		t.Skip("current user unknown")
+	}
+	sh := NewInterp()
+	script := "echo ~" + u.Username + "/x ~no_such_user_zz; cd ~" + u.Username + "; pwd"
+	want := u.HomeDir + "/x ~no_such_user_zz\n" + u.HomeDir + "\n"
+	if got := runTest(t, sh, script); got != want {
+		t.Errorf("%q: got %q, want %q", script, got, want)
+	}
+}
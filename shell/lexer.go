@@ -0,0 +1,113 @@
+package shell
+
+import "strings"
+
+// tokenKind 是词法单元的类型
+type tokenKind int
+
+const (
+	tokenWord     tokenKind = iota // 单词
+	tokenOperator                  // 控制操作符 ; | || && ( ) 换行
+	tokenRedirect                  // 重定向操作符，例如 > 2>> <& &>
+)
+
+// token 是命令行中的一个词法单元
+type token struct {
+	kind  tokenKind
+	text  string // 原始文本
+	value string // 单词去掉引号和转义后的内容
+	start int    // 在输入中的起始位置
+	end   int    // 在输入中的结束位置
+	quote byte   // 单词在输入结束时仍未闭合的引号，0 表示没有
+}
+
+// controlOperators 按长度从长到短排列，保证优先匹配较长的操作符
+var controlOperators = []string{"&&", "||", ";", "|", "(", ")", "\n"}
+
+// lexLine 把命令行拆分为词法单元，规则与 listParser 和 parseRedirectSpec 一致：
+// 单词以引号外的空白或控制操作符结束，单词开头的 [n]> 等是重定向操作符，# 开头的单词到行尾为注释
+// 输入可以不完整（例如补全时只有光标之前的部分），最后一个单词的引号可以未闭合
+func lexLine(src string) []token {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		ch := src[i]
+		if ch == ' ' || ch == '\t' {
+			i++
+			continue
+		}
+		if ch == '#' {
+			if end := strings.IndexByte(src[i:], '\n'); end != -1 {
+				i += end
+			} else {
+				i = len(src)
+			}
+			continue
+		}
+		if op, ok := parseRedirectWord(src[i:]); ok {
+			n := len(src) - i - len(op.target)
+			tokens = append(tokens, token{kind: tokenRedirect, text: src[i : i+n], start: i, end: i + n})
+			i += n
+			continue
+		}
+		if op := matchControlOperator(src[i:]); op != "" {
+			tokens = append(tokens, token{kind: tokenOperator, text: op, start: i, end: i + len(op)})
+			i += len(op)
+			continue
+		}
+		word := lexWord(src, i)
+		tokens = append(tokens, word)
+		i = word.end
+	}
+	return tokens
+}
+
+func matchControlOperator(s string) string {
+	for _, op := range controlOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// lexWord 从 start 开始读取一个单词，处理单引号、双引号和反斜杠转义
+func lexWord(src string, start int) token {
+	word := token{kind: tokenWord, start: start}
+	var value strings.Builder
+	i := start
+loop:
+	for ; i < len(src); i++ {
+		ch := src[i]
+		switch {
+		case word.quote == '\'':
+			if ch == '\'' {
+				word.quote = 0
+			} else {
+				value.WriteByte(ch)
+			}
+		case word.quote == '"':
+			if ch == '"' {
+				word.quote = 0
+			} else if ch == '\\' && i+1 < len(src) && strings.IndexByte("\"\\$`", src[i+1]) != -1 {
+				i++
+				value.WriteByte(src[i])
+			} else {
+				value.WriteByte(ch)
+			}
+		case ch == '\'' || ch == '"':
+			word.quote = ch
+		case ch == '\\' && i+1 < len(src):
+			i++
+			value.WriteByte(src[i])
+		case ch == ' ' || ch == '\t' || matchControlOperator(src[i:]) != "":
+			break loop
+		default:
+			value.WriteByte(ch)
+		}
+	}
+	word.end = i
+	word.text = src[start:i]
+	word.value = value.String()
+	return word
+}
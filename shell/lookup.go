@@ -0,0 +1,397 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go_shell/utils"
+)
+
+// shellKeywords 是解析器识别的保留字
+var shellKeywords = []string{"{", "}", "function"}
+
+// defaultPath 是 command -p 使用的标准 PATH，保证能找到系统工具
+const defaultPath = "/usr/bin:/bin:/usr/sbin:/sbin"
+
+// hashEntry 是 hash 表中的一项：外部命令的完整路径以及通过 hash 表找到它的次数
+type hashEntry struct {
+	path string
+	hits int
+}
+
+// commandLocation 是命令名的一个定义
+type commandLocation struct {
+	kind   string // "alias"、"keyword"、"function"、"builtin" 或 "file"
+	path   string // kind 为 "file" 时的完整路径
+	value  string // kind 为 "alias" 时别名的值
+	hashed bool   // 路径是否来自 hash 表
+}
+
+func isShellKeyword(name string) bool {
+	for _, keyword := range shellKeywords {
+		if keyword == name {
+			return true
+		}
+	}
+	return false
+}
+
+// locateCommand 按别名、保留字、函数、内置命令、hash 表、PATH 的顺序查找命令名的定义
+// all 为 false 时只返回实际会执行的第一个定义，否则返回所有定义（包括 PATH 中的每个同名文件）
+// noFuncs 跳过函数，pathOnly 只搜索 PATH
+func (sh *Interp) locateCommand(name string, all bool, noFuncs bool, pathOnly bool) []commandLocation {
+	var locations []commandLocation
+	if !pathOnly {
+		if value, ok := sh.aliases[name]; ok {
+			locations = append(locations, commandLocation{kind: "alias", value: value})
+		}
+		if isShellKeyword(name) {
+			locations = append(locations, commandLocation{kind: "keyword"})
+		}
+		if _, ok := sh.funcs[name]; ok && !noFuncs {
+			locations = append(locations, commandLocation{kind: "function"})
+		}
+		if isBuiltinCommand(name) {
+			locations = append(locations, commandLocation{kind: "builtin"})
+		}
+		if len(locations) > 0 && !all {
+			return locations[:1]
+		}
+		if entry, ok := sh.hashed[name]; ok && !all && utils.CheckExecutable(entry.path) == nil {
+			return append(locations, commandLocation{kind: "file", path: entry.path, hashed: true})
+		}
+	}
+	if strings.Contains(name, "/") {
+		// 带路径的命令不搜索 PATH
+		if utils.CheckExecutable(sh.resolvePath(name)) == nil {
+			locations = append(locations, commandLocation{kind: "file", path: name})
+		}
+		return locations
+	}
+	if !all {
+		if path, err := utils.FindExecutable(name, sh.getVar("PATH"), sh.dir); err == nil {
+			locations = append(locations, commandLocation{kind: "file", path: path})
+		}
+		return locations
+	}
+	for _, path := range utils.SearchPath(name, sh.getVar("PATH"), sh.dir) {
+		locations = append(locations, commandLocation{kind: "file", path: path})
+	}
+	return locations
+}
+
+// lookupCommand 查找外部命令的完整路径，先查 hash 表，找不到时搜索 PATH 并记录到 hash 表
+// hash 表中的文件已被删除时重新搜索；带路径的命令直接检查该文件，不记录到 hash 表
+func (sh *Interp) lookupCommand(name string) (string, error) {
+	if strings.Contains(name, "/") {
+		return utils.FindExecutable(name, "", sh.dir)
+	}
+	if entry, ok := sh.hashed[name]; ok && utils.CheckExecutable(entry.path) == nil {
+		entry.hits++
+		sh.hashed[name] = entry
+		return entry.path, nil
+	}
+	delete(sh.hashed, name)
+	path, err := utils.FindExecutable(name, sh.getVar("PATH"), sh.dir)
+	if err != nil {
+		return "", err
+	}
+	sh.hashed[name] = hashEntry{path: path, hits: 1}
+	return path, nil
+}
+
+// 处理 type 命令
+//
+//	type [-afptP] name ...
+//	-a 列出所有定义  -f 跳过函数  -t 只输出类型（alias、keyword、function、builtin、file）
+//	-p 只输出会执行的文件路径  -P 即使是内置命令或函数也搜索 PATH
+func runTypeBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	var all, noFuncs, typeOnly, pathOnly, forcePath bool
+	args := cmdSlice[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'a':
+				all = true
+			case 'f':
+				noFuncs = true
+			case 't':
+				typeOnly = true
+			case 'p':
+				pathOnly = true
+			case 'P':
+				forcePath = true
+			default:
+				fmt.Fprintf(errorWriter, "type: -%c: invalid option\n", flag)
+				fmt.Fprintln(errorWriter, "type: usage: type [-afptP] name [name ...]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+
+	status := 0
+	for _, name := range args {
+		locations := sh.locateCommand(name, all, noFuncs, forcePath)
+		if len(locations) == 0 {
+			if !typeOnly && !pathOnly && !forcePath {
+				fmt.Fprintf(errorWriter, "%s: not found\n", name)
+			}
+			status = 1
+			continue
+		}
+		for _, loc := range locations {
+			switch {
+			case typeOnly:
+				fmt.Fprintln(writer, loc.kind)
+			case pathOnly || forcePath:
+				if loc.kind == "file" {
+					fmt.Fprintln(writer, loc.path)
+				}
+			default:
+				fmt.Fprintln(writer, describeCommand(name, loc))
+			}
+		}
+	}
+	return status
+}
+
+// describeCommand 返回 type 和 command -V 对命令定义的描述
+func describeCommand(name string, loc commandLocation) string {
+	switch loc.kind {
+	case "alias":
+		return fmt.Sprintf("%s is aliased to `%s'", name, loc.value)
+	case "keyword":
+		return name + " is a shell keyword"
+	case "function":
+		return name + " is a function"
+	case "builtin":
+		return name + " is a shell builtin"
+	}
+	if loc.hashed {
+		return fmt.Sprintf("%s is hashed (%s)", name, loc.path)
+	}
+	return fmt.Sprintf("%s is %s", name, loc.path)
+}
+
+// 处理 command 命令：跳过同名函数执行内置命令或外部程序
+//
+//	command [-p] name [args]  -p 使用标准 PATH 查找外部程序
+//	command -v name ...       输出命令名（内置命令、函数、保留字）、别名定义或完整路径
+//	command -V name ...       以 type 的格式描述命令
+func runCommandBuiltin(sh *Interp, cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	var standardPath, brief, verbose bool
+	args := cmd.args[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'p':
+				standardPath = true
+			case 'v':
+				brief = true
+			case 'V':
+				verbose = true
+			default:
+				fmt.Fprintf(stderr, "command: -%c: invalid option\n", flag)
+				fmt.Fprintln(stderr, "command: usage: command [-pVv] command [arg ...]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return 0
+	}
+
+	if brief || verbose {
+		status := 0
+		for _, name := range args {
+			locations := sh.locateCommand(name, false, false, false)
+			if len(locations) == 0 {
+				if verbose {
+					fmt.Fprintf(stderr, "command: %s: not found\n", name)
+				}
+				status = 1
+				continue
+			}
+			switch {
+			case verbose:
+				fmt.Fprintln(stdout, describeCommand(name, locations[0]))
+			case locations[0].kind == "file":
+				fmt.Fprintln(stdout, locations[0].path)
+			case locations[0].kind == "alias":
+				fmt.Fprintln(stdout, formatAlias(name, locations[0].value))
+			default:
+				fmt.Fprintln(stdout, name)
+			}
+		}
+		return status
+	}
+
+	cmd = shiftCommand(cmd, len(cmd.args)-len(args))
+	if cmd.isBuiltin {
+		return runBuiltinCommand(sh, cmd, stdin, stdout, stderr)
+	}
+	if !standardPath {
+		return runExternalCommand(sh, cmd, stdin, stdout, stderr)
+	}
+	fullPath, err := utils.FindExecutable(cmd.args[0], defaultPath, sh.dir)
+	if err != nil {
+		return sh.commandFailed(cmd, err, stdin, stdout, stderr)
+	}
+	err = sh.newExternalCmd(fullPath, cmd, stdin, stdout, stderr).Run()
+	if isExecFormatError(err) {
+		return sh.runScript(fullPath, cmd, stdin, stdout, stderr)
+	}
+	return exitStatus(err)
+}
+
+// 处理 builtin 命令：跳过同名函数执行内置命令
+func runBuiltinBuiltin(sh *Interp, cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(cmd.args) < 2 {
+		return 0
+	}
+	if !isBuiltinCommand(cmd.args[1]) {
+		fmt.Fprintf(stderr, "builtin: %s: not a shell builtin\n", cmd.args[1])
+		return 1
+	}
+	return runBuiltinCommand(sh, shiftCommand(cmd, 1), stdin, stdout, stderr)
+}
+
+// shiftCommand 去掉命令的前 n 个参数（如 command 和 builtin 本身），剩余部分作为新命令
+// echo 直接处理原始文本，需要用剩余的参数重新生成
+func shiftCommand(cmd pipelineCommand, n int) pipelineCommand {
+	cmd.args = cmd.args[n:]
+	cmd.raw = quoteWords(cmd.args)
+	cmd.isBuiltin = isBuiltinCommand(cmd.args[0])
+	return cmd
+}
+
+// 处理 hash 命令
+//
+//	hash                  列出 hash 表中的命令及命中次数
+//	hash [-r] [name ...]  -r 清空 hash 表；name 在 PATH 中查找并加入 hash 表
+//	hash -d name ...      从 hash 表中删除
+//	hash -p path name     把 name 记录为 path
+//	hash -t name ...      输出 name 在 hash 表中的路径
+//	hash -l               以可重新执行的形式输出
+func runHashBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	var reset, remove, listPaths, reusable bool
+	var path string
+	args := cmdSlice[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 'r':
+				reset = true
+			case 'd':
+				remove = true
+			case 't':
+				listPaths = true
+			case 'l':
+				reusable = true
+			case 'p':
+				if len(args) < 2 {
+					fmt.Fprintln(errorWriter, "hash: -p: option requires an argument")
+					return 2
+				}
+				path = args[1]
+				args = args[1:]
+			default:
+				fmt.Fprintf(errorWriter, "hash: -%c: invalid option\n", flag)
+				fmt.Fprintln(errorWriter, "hash: usage: hash [-lr] [-p pathname] [-dt] [name ...]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+
+	if reset {
+		clear(sh.hashed)
+		utils.ResetExecutableCache()
+		// 补全使用的命令 Trie 也重新扫描 PATH
+		sh.commands.invalidate()
+	}
+	if len(args) == 0 {
+		if !reset {
+			printHashTable(sh, reusable, writer)
+		}
+		return 0
+	}
+
+	status := 0
+	for _, name := range args {
+		entry, ok := sh.hashed[name]
+		switch {
+		case path != "":
+			sh.hashed[name] = hashEntry{path: path}
+		case remove:
+			if !ok {
+				fmt.Fprintf(errorWriter, "hash: %s: not found\n", name)
+				status = 1
+			}
+			delete(sh.hashed, name)
+			utils.ForgetExecutable(name)
+		case listPaths:
+			if !ok {
+				fmt.Fprintf(errorWriter, "hash: %s: not found\n", name)
+				status = 1
+			} else if len(args) > 1 {
+				fmt.Fprintf(writer, "%s\t%s\n", name, entry.path)
+			} else {
+				fmt.Fprintln(writer, entry.path)
+			}
+		case isBuiltinCommand(name) || strings.Contains(name, "/"):
+			// 内置命令和带路径的命令不需要记录
+		default:
+			// hash name 重新搜索 PATH，不使用 FindExecutable 缓存的旧路径
+			utils.ForgetExecutable(name)
+			fullPath, err := utils.FindExecutable(name, sh.getVar("PATH"), sh.dir)
+			if err != nil {
+				fmt.Fprintf(errorWriter, "hash: %s: not found\n", name)
+				status = 1
+				continue
+			}
+			sh.hashed[name] = hashEntry{path: fullPath}
+		}
+	}
+	return status
+}
+
+// printHashTable 按命令名排序输出 hash 表
+func printHashTable(sh *Interp, reusable bool, writer io.Writer) {
+	if len(sh.hashed) == 0 {
+		fmt.Fprintln(writer, "hash: hash table empty")
+		return
+	}
+	names := make([]string, 0, len(sh.hashed))
+	for name := range sh.hashed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if !reusable {
+		fmt.Fprintln(writer, "hits\tcommand")
+	}
+	for _, name := range names {
+		entry := sh.hashed[name]
+		if reusable {
+			fmt.Fprintf(writer, "builtin hash -p %s %s\n", shellQuote(entry.path), shellQuote(name))
+		} else {
+			fmt.Fprintf(writer, "%4d\t%s\n", entry.hits, entry.path)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// setOptions 是 set -o 支持的选项，flag 为对应的单字母选项（0 表示只能通过 -o 设置）
+var setOptions = []struct {
+	name string
+	flag byte
+}{
+	{"errexit", 'e'},   // 命令失败时退出 Shell
+	{"noclobber", 'C'}, // > 不覆盖已存在的文件
+	{"noglob", 'f'},    // 禁用文件名展开
+	{"nounset", 'u'},   // 展开未设置的变量时报错
+	{"pipefail", 0},    // 管道的退出状态取最后一个失败的命令
+	{"xtrace", 'x'},    // 执行前把命令输出到标准错误，前缀为 PS4
+}
+
+// shoptOptions 是 shopt 支持的扩展选项
+var shoptOptions = []string{
+	"complete_fuzzy",     // 补全命令名和文件名时按子序列模糊匹配（与 fzf 类似）
+	"complete_smartcase", // 补全时输入中没有大写字母则不区分大小写
+	"complete_substring", // 补全命令名和文件名时匹配包含输入的名称，而不只是以输入开头的名称
+	"dotglob",            // 文件名展开包含以 . 开头的文件
+	"nullglob",           // 没有匹配的模式展开为空，而不是保留原样
+}
+
+// setOptionByFlag 返回单字母选项对应的选项名
+func setOptionByFlag(flag byte) (string, bool) {
+	for _, opt := range setOptions {
+		if opt.flag != 0 && opt.flag == flag {
+			return opt.name, true
+		}
+	}
+	return "", false
+}
+
+func isSetOption(name string) bool {
+	for _, opt := range setOptions {
+		if opt.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isShoptOption(name string) bool {
+	for _, opt := range shoptOptions {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+// optionFlags 返回 $- 的值，即当前开启的单字母选项
+func (sh *Interp) optionFlags() string {
+	var flags strings.Builder
+	for _, flag := range []byte("efuxC") {
+		if name, _ := setOptionByFlag(flag); sh.options[name] {
+			flags.WriteByte(flag)
+		}
+	}
+	return flags.String()
+}
+
+// 处理 set 命令
+//
+//	set                  按名称排序列出所有变量
+//	set -efuxC / +efuxC  开启 / 关闭单字母选项
+//	set -o name / +o name 开启 / 关闭选项，不带名称时列出所有选项
+//	set [--] args        设置位置参数
+func runSetBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	args := cmdSlice[1:]
+	if len(args) == 0 {
+		names := make([]string, 0, len(sh.vars))
+		for name := range sh.vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if elements, ok := sh.arrays[name]; ok {
+				fmt.Fprintf(writer, "%s=(%s)\n", name, quoteWords(elements))
+				continue
+			}
+			fmt.Fprintf(writer, "%s=%s\n", name, shellQuote(sh.vars[name]))
+		}
+		return 0
+	}
+
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			sh.args = args[1:]
+			return 0
+		}
+		if len(arg) < 2 || (arg[0] != '-' && arg[0] != '+') {
+			break
+		}
+		enable := arg[0] == '-'
+		args = args[1:]
+		for i := 1; i < len(arg); i++ {
+			if arg[i] == 'o' {
+				if len(args) == 0 {
+					printSetOptions(sh, enable, writer)
+					continue
+				}
+				name := args[0]
+				args = args[1:]
+				if !isSetOption(name) {
+					fmt.Fprintf(errorWriter, "set: %s: invalid option name\n", name)
+					return 2
+				}
+				sh.options[name] = enable
+				continue
+			}
+			name, ok := setOptionByFlag(arg[i])
+			if !ok {
+				fmt.Fprintf(errorWriter, "set: %c%c: invalid option\n", arg[0], arg[i])
+				fmt.Fprintln(errorWriter, "set: usage: set [-efuxC] [-o option-name] [--] [arg ...]")
+				return 2
+			}
+			sh.options[name] = enable
+		}
+	}
+	if len(args) > 0 {
+		sh.args = args
+	}
+	return 0
+}
+
+// printSetOptions 列出 set -o 的选项状态；set +o 输出可以重新执行的 set 命令
+func printSetOptions(sh *Interp, enable bool, writer io.Writer) {
+	for _, opt := range setOptions {
+		switch {
+		case !enable && sh.options[opt.name]:
+			fmt.Fprintf(writer, "set -o %s\n", opt.name)
+		case !enable:
+			fmt.Fprintf(writer, "set +o %s\n", opt.name)
+		default:
+			fmt.Fprintf(writer, "%-15s\t%s\n", opt.name, onOff(sh.options[opt.name]))
+		}
+	}
+}
+
+func onOff(enabled bool) string {
+	if enabled {
+		return "on"
+	}
+	return "off"
+}
+
+// 处理 shopt 命令
+//
+//	shopt [-pqsu] [-o] [optname ...]
+//	-s 开启  -u 关闭  -p 以可重新执行的形式输出  -q 不输出，只通过退出状态返回结果
+//	-o 操作 set -o 的选项
+func runShoptBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	var set, unset, printable, quiet, setStyle bool
+	args := cmdSlice[1:]
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 {
+		if args[0] == "--" {
+			args = args[1:]
+			break
+		}
+		for _, flag := range args[0][1:] {
+			switch flag {
+			case 's':
+				set = true
+			case 'u':
+				unset = true
+			case 'p':
+				printable = true
+			case 'q':
+				quiet = true
+			case 'o':
+				setStyle = true
+			default:
+				fmt.Fprintf(errorWriter, "shopt: -%c: invalid option\n", flag)
+				fmt.Fprintln(errorWriter, "shopt: usage: shopt [-pqsu] [-o] [optname ...]")
+				return 2
+			}
+		}
+		args = args[1:]
+	}
+	if set && unset {
+		fmt.Fprintln(errorWriter, "shopt: cannot set and unset shell options simultaneously")
+		return 1
+	}
+
+	valid := isShoptOption
+	names := shoptOptions
+	if setStyle {
+		valid = isSetOption
+		names = make([]string, len(setOptions))
+		for i, opt := range setOptions {
+			names[i] = opt.name
+		}
+	}
+	for _, name := range args {
+		if !valid(name) {
+			fmt.Fprintf(errorWriter, "shopt: %s: invalid shell option name\n", name)
+			return 1
+		}
+	}
+
+	if set || unset {
+		for _, name := range args {
+			sh.options[name] = set
+		}
+		if len(args) > 0 {
+			return 0
+		}
+	}
+
+	// 没有指定选项名时列出所有选项（-s / -u 时只列出开启 / 关闭的选项）
+	listAll := len(args) == 0
+	if listAll {
+		args = names
+	}
+	status := 0
+	for _, name := range args {
+		enabled := sh.options[name]
+		if listAll && ((set && !enabled) || (unset && enabled)) {
+			continue
+		}
+		if !enabled {
+			status = 1
+		}
+		if quiet {
+			continue
+		}
+		if !printable {
+			fmt.Fprintf(writer, "%-15s\t%s\n", name, onOff(enabled))
+			continue
+		}
+		if setStyle {
+			flag := "+o"
+			if enabled {
+				flag = "-o"
+			}
+			fmt.Fprintf(writer, "set %s %s\n", flag, name)
+		} else {
+			flag := "-u"
+			if enabled {
+				flag = "-s"
+			}
+			fmt.Fprintf(writer, "shopt %s %s\n", flag, name)
+		}
+	}
+	if listAll && !quiet {
+		return 0
+	}
+	return status
+}
+
+// quoteWords 把单词重新组合成命令文本，必要时加上引号
+func quoteWords(words []string) string {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = shellQuote(word)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuote 在需要时为字符串加上单引号，使其可以作为一个单词重新输入
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n'\"\\$|&;()<>*?[]#~`{}") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
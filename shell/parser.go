@@ -1,64 +1,63 @@
 package shell
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
-// ParsePipe 解析管道操作符，将命令分割成多个部分
-// 返回命令部分列表，每个部分是一个命令及其参数
-func ParsePipe(command string) []string {
-	var parts []string
-	var currentPart strings.Builder
-	inSingleQuotes := false
-	inDoubleQuotes := false
-	bytes := []byte(command)
-
-	for i := 0; i < len(bytes); i++ {
-		if bytes[i] == '\'' {
-			if inSingleQuotes {
-				inSingleQuotes = false
-				if i+1 < len(bytes) && bytes[i+1] == '\'' {
-					i++
-					inSingleQuotes = true
-					continue
-				}
-			} else if !inDoubleQuotes {
-				inSingleQuotes = true
-			}
-			currentPart.WriteByte(bytes[i])
-		} else if bytes[i] == '"' {
-			if inDoubleQuotes {
-				inDoubleQuotes = false
-				if i+1 < len(bytes) && bytes[i+1] == '"' {
-					i++
-					inDoubleQuotes = true
-					continue
-				}
-			} else if !inSingleQuotes {
-				inDoubleQuotes = true
-			}
-			currentPart.WriteByte(bytes[i])
-		} else if !inSingleQuotes && !inDoubleQuotes && bytes[i] == '|' {
-			// 遇到管道操作符，保存当前部分并开始新部分
-			if currentPart.Len() > 0 {
-				parts = append(parts, strings.TrimSpace(currentPart.String()))
-				currentPart.Reset()
-			}
-		} else {
-			currentPart.WriteByte(bytes[i])
-		}
+// ParseCommand 解析命令，处理单引号和双引号
+func ParseCommand(command string) []string {
+	words := parseWords(command)
+	args := make([]string, 0, len(words))
+	for _, word := range words {
+		args = append(args, word.text)
 	}
-
-	// 添加最后一部分
-	if currentPart.Len() > 0 {
-		parts = append(parts, strings.TrimSpace(currentPart.String()))
+	if len(args) == 0 {
+		return nil
 	}
+	return args
+}
 
-	return parts
+// shellWord 是命令中的一个单词，pattern 是用于文件名展开的模式，其中引号内的通配符已被转义
+type shellWord struct {
+	text    string
+	pattern string
+	glob    bool // 是否包含引号之外的通配符 * ? [
 }
 
-// ParseCommand 解析命令，处理单引号和双引号
-func ParseCommand(command string) []string {
-	var args []string
-	var currentArg strings.Builder
+// wordBuilder 逐个字符构造单词，同时记录字符是否位于引号内
+type wordBuilder struct {
+	text    strings.Builder
+	pattern strings.Builder
+	glob    bool
+}
+
+func (w *wordBuilder) add(ch byte, quoted bool) {
+	w.text.WriteByte(ch)
+	if strings.IndexByte("*?[\\", ch) != -1 {
+		if quoted {
+			w.pattern.WriteByte('\\')
+		} else if ch != '\\' {
+			w.glob = true
+		}
+	}
+	w.pattern.WriteByte(ch)
+}
+
+// parseWords 按引号规则拆分单词，ParseCommand 和文件名展开共用
+func parseWords(command string) []shellWord {
+	var words []shellWord
+	var currentArg wordBuilder
+	flush := func() {
+		if currentArg.text.Len() > 0 {
+			words = append(words, shellWord{
+				text:    currentArg.text.String(),
+				pattern: currentArg.pattern.String(),
+				glob:    currentArg.glob,
+			})
+		}
+		currentArg = wordBuilder{}
+	}
 	inSingleQuotes := false
 	inDoubleQuotes := false
 	bytes := []byte(command)
@@ -77,11 +76,7 @@ func ParseCommand(command string) []string {
 				}
 			} else if !inDoubleQuotes {
 				// 开始单引号（不在双引号内）
-				// 如果当前有参数，先保存
-				if currentArg.Len() > 0 {
-					args = append(args, currentArg.String())
-					currentArg.Reset()
-				}
+				// 引号与前面的字符属于同一个参数，例如 PS1='$ ' 或 a'b'
 				// 检查是否是空引号 ''
 				if i+1 < len(bytes) && bytes[i+1] == '\'' {
 					// 空引号，忽略
@@ -91,7 +86,7 @@ func ParseCommand(command string) []string {
 				inSingleQuotes = true
 			} else {
 				// 在双引号内的单引号，按字面处理
-				currentArg.WriteByte(bytes[i])
+				currentArg.add(bytes[i], true)
 			}
 		} else if bytes[i] == '"' {
 			if inDoubleQuotes {
@@ -106,11 +101,7 @@ func ParseCommand(command string) []string {
 				}
 			} else if !inSingleQuotes {
 				// 开始双引号（不在单引号内）
-				// 如果当前有参数，先保存
-				if currentArg.Len() > 0 {
-					args = append(args, currentArg.String())
-					currentArg.Reset()
-				}
+				// 引号与前面的字符属于同一个参数，例如 FOO="a b"
 				// 检查是否是空引号 ""
 				if i+1 < len(bytes) && bytes[i+1] == '"' {
 					// 空引号，忽略
@@ -120,99 +111,428 @@ func ParseCommand(command string) []string {
 				inDoubleQuotes = true
 			} else {
 				// 在单引号内的双引号，按字面处理
-				currentArg.WriteByte(bytes[i])
+				currentArg.add(bytes[i], true)
 			}
 		} else if !inSingleQuotes && !inDoubleQuotes && (bytes[i] == ' ' || bytes[i] == '\t') {
 			// 引号外的空格，作为分隔符
-			if currentArg.Len() > 0 {
-				args = append(args, currentArg.String())
-				currentArg.Reset()
-			}
+			flush()
 		} else if inDoubleQuotes && bytes[i] == '\\' {
 			// 在双引号内遇到反斜杠，处理转义
 			if i+1 < len(bytes) {
 				switch bytes[i+1] {
 				case '"':
-					currentArg.WriteByte('"')
+					currentArg.add('"', true)
 					i++ // 跳过下一个字符
 				case '\\':
-					currentArg.WriteByte('\\')
+					currentArg.add('\\', true)
 					i++ // 跳过下一个字符
 				default:
-					currentArg.WriteByte(bytes[i])
+					currentArg.add(bytes[i], true)
 				}
 			} else {
 				// 反斜杠是最后一个字符，按字面处理
-				currentArg.WriteByte(bytes[i])
+				currentArg.add(bytes[i], true)
 			}
 		} else {
 			// 普通字符，添加到当前参数
-			currentArg.WriteByte(bytes[i])
+			currentArg.add(bytes[i], inSingleQuotes || inDoubleQuotes)
 		}
 	}
 
 	// 添加最后一个参数
-	if currentArg.Len() > 0 {
-		args = append(args, currentArg.String())
+	flush()
+	return words
+}
+
+// 命令列表的语法树，语法如下：
+//
+//	list     := andOr { (';' | '\n') andOr }
+//	andOr    := pipeline { ('&&' | '||') pipeline }
+//	pipeline := stage { '|' stage }
+//	stage    := '(' list ')' redirect | '{' list '}' redirect | function | simple
+//	function := name '()' stage | 'function' name ['()'] stage
+//
+// 简单命令保留原始文本，执行时再交给 ParseCommand 和 parseRedirectSpec 处理
+// 引号外以 # 开头的单词到行尾为注释
+type listNode struct {
+	items []*andOrNode
+}
+
+type andOrNode struct {
+	pipelines []*pipelineNode
+	ops       []string // ops[i] 连接 pipelines[i] 和 pipelines[i+1]，取值为 "&&" 或 "||"
+}
+
+type pipelineNode struct {
+	stages []*stageNode
+}
+
+type stageNode struct {
+	raw      string        // 简单命令的原始文本
+	group    *groupNode    // 子 Shell ( ... ) 或命令组 { ...; }
+	function *functionNode // 函数定义
+}
+
+type functionNode struct {
+	name string
+	body *groupNode
+}
+
+type groupNode struct {
+	subshell bool // true 表示 ( ... )，在子 Shell 中执行
+	body     *listNode
+	redirect string // 组之后的重定向文本，例如 "> out"
+}
+
+// syntaxError 表示命令行存在语法错误，token 为空表示输入意外结束
+type syntaxError struct {
+	token string
+}
+
+func (e *syntaxError) Error() string {
+	if e.token == "" {
+		return "syntax error: unexpected end of file"
 	}
+	return fmt.Sprintf("syntax error near unexpected token `%s'", e.token)
+}
 
-	return args
+type listParser struct {
+	src string
+	pos int
+}
+
+// parseList 将一行输入解析为命令列表
+func parseList(input string) (*listNode, error) {
+	p := &listParser{src: input}
+	list, err := p.parseList("")
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+// parseList 解析命令列表，直到输入结束或遇到 terminator（")" 或 "}"）
+func (p *listParser) parseList(terminator string) (*listNode, error) {
+	list := &listNode{}
+	for {
+		p.skipBlanks(true)
+		if p.atEnd() || p.atTerminator(terminator) {
+			return list, nil
+		}
+		item, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+
+		p.skipBlanks(false)
+		if p.peek(";") || p.peek("\n") {
+			p.pos++
+			continue
+		}
+		if p.atEnd() || p.atTerminator(terminator) {
+			return list, nil
+		}
+		return nil, p.unexpected()
+	}
+}
+
+func (p *listParser) parseAndOr() (*andOrNode, error) {
+	node := &andOrNode{}
+	for {
+		pipeline, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		node.pipelines = append(node.pipelines, pipeline)
+
+		p.skipBlanks(false)
+		if !p.peek("&&") && !p.peek("||") {
+			return node, nil
+		}
+		node.ops = append(node.ops, p.src[p.pos:p.pos+2])
+		p.pos += 2
+		// 操作符之后允许换行
+		p.skipBlanks(true)
+	}
+}
+
+func (p *listParser) parsePipeline() (*pipelineNode, error) {
+	node := &pipelineNode{}
+	for {
+		stage, err := p.parseStage()
+		if err != nil {
+			return nil, err
+		}
+		node.stages = append(node.stages, stage)
+
+		p.skipBlanks(false)
+		if !p.peek("|") || p.peek("||") {
+			return node, nil
+		}
+		p.pos++
+		p.skipBlanks(true)
+	}
+}
+
+func (p *listParser) parseStage() (*stageNode, error) {
+	p.skipBlanks(false)
+	if p.atEnd() {
+		return nil, &syntaxError{}
+	}
+
+	if name, ok, err := p.readFunctionHeader(); err != nil {
+		return nil, err
+	} else if ok {
+		// 函数体必须是 { ...; } 或 ( ... )
+		p.skipBlanks(true)
+		body, err := p.parseStage()
+		if err != nil {
+			return nil, err
+		}
+		if body.group == nil {
+			return nil, &syntaxError{token: strings.Fields(body.raw)[0]}
+		}
+		return &stageNode{function: &functionNode{name: name, body: body.group}}, nil
+	}
+
+	var group *groupNode
+	if p.peek("(") {
+		p.pos++
+		body, err := p.parseList(")")
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, p.unexpected()
+		}
+		if len(body.items) == 0 {
+			return nil, &syntaxError{token: ")"}
+		}
+		p.pos++
+		group = &groupNode{subshell: true, body: body}
+	} else if p.atWord("{") {
+		p.pos++
+		body, err := p.parseList("}")
+		if err != nil {
+			return nil, err
+		}
+		if !p.atWord("}") {
+			return nil, p.unexpected()
+		}
+		if len(body.items) == 0 {
+			return nil, &syntaxError{token: "}"}
+		}
+		p.pos++
+		group = &groupNode{body: body}
+	}
+
+	if group != nil {
+		// 组之后只允许出现重定向
+		redirect, err := p.readSimple()
+		if err != nil {
+			return nil, err
+		}
+		if rest, _ := parseRedirectSpec(ParseCommand(redirect)); len(rest) > 0 {
+			return nil, &syntaxError{token: rest[0]}
+		}
+		group.redirect = strings.TrimSpace(redirect)
+		return &stageNode{group: group}, nil
+	}
+
+	raw, err := p.readSimple()
+	if err != nil {
+		return nil, err
+	}
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, p.unexpected()
+	}
+	return &stageNode{raw: raw}, nil
 }
 
-// ParseRedirect 解析重定向操作符，返回命令部分、标准输出文件和标准错误文件
-func ParseRedirect(cmdSlice []string) ([]string, string, string, string, string) {
-	var stdoutFile string
-	var stdappendFile string
-	var stderrFile string
-	var stderrappendFile string
-	actualCmdSlice := cmdSlice
-
-	// 从后往前查找重定向操作符（支持多个重定向）
-	for i := len(cmdSlice) - 1; i >= 0; i-- {
-		arg := cmdSlice[i]
-		switch arg {
-		case ">", "1>":
-			if i+1 < len(cmdSlice) {
-				stdoutFile = cmdSlice[i+1]
-				actualCmdSlice = cmdSlice[:i]
+// readFunctionHeader 尝试读取函数定义的头部 "name()" 或 "function name"
+// 不是函数定义时恢复读取位置
+func (p *listParser) readFunctionHeader() (string, bool, error) {
+	start := p.pos
+	keyword := p.atWord("function")
+	if keyword {
+		p.pos += len("function")
+		p.skipBlanks(false)
+	}
+	nameStart := p.pos
+	for p.pos < len(p.src) && isFunctionNameChar(p.src[p.pos]) {
+		p.pos++
+	}
+	name := p.src[nameStart:p.pos]
+	if keyword && name == "" {
+		return "", false, p.unexpected()
+	}
+	p.skipBlanks(false)
+	if name != "" && p.peek("()") {
+		p.pos += 2
+		return name, true, nil
+	}
+	if keyword && name != "" {
+		return name, true, nil
+	}
+	p.pos = start
+	return "", false, nil
+}
+
+func isFunctionNameChar(ch byte) bool {
+	return ch == '_' || ch == '-' || ch == '.' || ch == ':' ||
+		(ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// readSimple 读取一条简单命令的原始文本，直到遇到引号外的控制操作符
+func (p *listParser) readSimple() (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		switch {
+		case ch == '#' && (p.pos == start || p.src[p.pos-1] == ' ' || p.src[p.pos-1] == '\t'):
+			// 注释：命令到此结束，跳过到行尾
+			text := p.src[start:p.pos]
+			p.skipComment()
+			return text, nil
+		case ch == '\'':
+			end := strings.IndexByte(p.src[p.pos+1:], '\'')
+			if end == -1 {
+				return "", &syntaxError{}
 			}
-		case "2>":
-			if i+1 < len(cmdSlice) {
-				stderrFile = cmdSlice[i+1]
-				actualCmdSlice = cmdSlice[:i]
+			p.pos += end + 2
+		case ch == '"':
+			p.pos++
+			for p.pos < len(p.src) && p.src[p.pos] != '"' {
+				if p.src[p.pos] == '\\' {
+					p.pos++
+				}
+				p.pos++
 			}
-		case ">>", "1>>":
-			if i+1 < len(cmdSlice) {
-				stdappendFile = cmdSlice[i+1]
-				actualCmdSlice = cmdSlice[:i]
+			if p.pos >= len(p.src) {
+				return "", &syntaxError{}
 			}
-		case "2>>":
-			if i+1 < len(cmdSlice) {
-				stderrappendFile = cmdSlice[i+1]
-				actualCmdSlice = cmdSlice[:i]
+			p.pos++
+		case ch == '\\':
+			p.pos += 2
+		case ch == '(' && endsWithAssignmentName(p.src[start:p.pos]):
+			// NAME=(...) 数组赋值，括号内的内容属于同一条命令
+			if !p.skipArrayElements() {
+				return "", &syntaxError{}
 			}
+		case p.peek(">|"):
+			// >| 是重定向操作符，其中的 | 不是管道
+			p.pos += 2
+		case ch == ';' || ch == '\n' || ch == '|' || ch == '(' || ch == ')' || p.peek("&&"):
+			return p.src[start:p.pos], nil
 		default:
-			if strings.HasPrefix(arg, "1>>") {
-				stdappendFile = strings.TrimPrefix(arg, "1>>")
-				actualCmdSlice = append(cmdSlice[:i], cmdSlice[i+1:]...)
-			} else if strings.HasPrefix(arg, ">>") {
-				stdappendFile = strings.TrimPrefix(arg, ">>")
-				actualCmdSlice = append(cmdSlice[:i], cmdSlice[i+1:]...)
-			} else if strings.HasPrefix(arg, "1>") {
-				stdoutFile = strings.TrimPrefix(arg, "1>")
-				actualCmdSlice = append(cmdSlice[:i], cmdSlice[i+1:]...)
-			} else if strings.HasPrefix(arg, ">") {
-				stdoutFile = strings.TrimPrefix(arg, ">")
-				actualCmdSlice = append(cmdSlice[:i], cmdSlice[i+1:]...)
-			} else if strings.HasPrefix(arg, "2>>") {
-				stderrappendFile = strings.TrimPrefix(arg, "2>>")
-				actualCmdSlice = append(cmdSlice[:i], cmdSlice[i+1:]...)
-			} else if strings.HasPrefix(arg, "2>") {
-				stderrFile = strings.TrimPrefix(arg, "2>")
-				actualCmdSlice = append(cmdSlice[:i], cmdSlice[i+1:]...)
+			p.pos++
+		}
+	}
+	if p.pos > len(p.src) {
+		p.pos = len(p.src)
+	}
+	return p.src[start:p.pos], nil
+}
+
+// skipArrayElements 跳过数组赋值的 ( ... )，括号内可以有引号和换行，没有找到 ) 时返回 false
+func (p *listParser) skipArrayElements() bool {
+	var quote byte
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		ch := p.src[p.pos]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '\\' && quote == '"' {
+				p.pos++
 			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '\\':
+			p.pos++
+		case ch == ')':
+			p.pos++
+			return true
 		}
 	}
+	return false
+}
+
+// endsWithAssignmentName 判断文本的最后一个单词是否是 NAME=，即之后的 ( 开始数组赋值
+func endsWithAssignmentName(text string) bool {
+	word := text[strings.LastIndexAny(text, " \t")+1:]
+	name, ok := strings.CutSuffix(word, "=")
+	return ok && isValidName(name)
+}
 
-	return actualCmdSlice, stdoutFile, stderrFile, stdappendFile, stderrappendFile
+// skipBlanks 跳过空格、制表符和注释，newline 为 true 时同时跳过换行
+func (p *listParser) skipBlanks(newline bool) {
+	for p.pos < len(p.src) {
+		ch := p.src[p.pos]
+		if ch == '#' {
+			p.skipComment()
+			continue
+		}
+		if ch != ' ' && ch != '\t' && (!newline || ch != '\n') {
+			return
+		}
+		p.pos++
+	}
+}
+
+// skipComment 跳过注释直到行尾（不包括换行符本身）
+func (p *listParser) skipComment() {
+	if end := strings.IndexByte(p.src[p.pos:], '\n'); end != -1 {
+		p.pos += end
+	} else {
+		p.pos = len(p.src)
+	}
+}
+
+func (p *listParser) atEnd() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *listParser) peek(s string) bool {
+	return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+// atWord 判断当前位置是否是一个独立的单词 word（例如保留字 "{" 和 "}"）
+func (p *listParser) atWord(word string) bool {
+	if !p.peek(word) {
+		return false
+	}
+	next := p.pos + len(word)
+	return next >= len(p.src) || strings.IndexByte(" \t\n;|&()<>", p.src[next]) != -1
+}
+
+func (p *listParser) atTerminator(terminator string) bool {
+	switch terminator {
+	case ")":
+		return p.peek(")")
+	case "}":
+		return p.atWord("}")
+	}
+	return false
+}
+
+// unexpected 根据当前位置的内容构造语法错误
+func (p *listParser) unexpected() error {
+	if p.atEnd() {
+		return &syntaxError{}
+	}
+	for _, op := range []string{"&&", "||", ";;"} {
+		if p.peek(op) {
+			return &syntaxError{token: op}
+		}
+	}
+	if p.src[p.pos] == '\n' {
+		return &syntaxError{token: "newline"}
+	}
+	return &syntaxError{token: p.src[p.pos : p.pos+1]}
 }
@@ -5,20 +5,21 @@ import (
 	"io"
 	"os"
 	"os/exec"
-	"strings"
-
-	"go_shell/utils"
 )
 
 type pipelineCommand struct {
 	args      []string
 	raw       string
 	isBuiltin bool
+	assigns   []string         // 命令前的临时变量赋值，如 FOO=bar cmd
+	redirect  redirectSpec     // 该命令自身的重定向
+	fds       map[int]*os.File // 打开重定向后 3 及以上的文件描述符，传递给外部程序
+	group     *groupNode       // 非空表示子 Shell 或命令组
 }
 
 type pipelineProcess interface {
 	Start() error
-	Wait() error
+	Wait() int
 }
 
 type externalProcess struct {
@@ -29,21 +30,26 @@ func (p *externalProcess) Start() error {
 	return p.cmd.Start()
 }
 
-func (p *externalProcess) Wait() error {
-	return p.cmd.Wait()
+func (p *externalProcess) Wait() int {
+	return exitStatus(p.cmd.Wait())
 }
 
+// builtinProcess 在 goroutine 中执行内置命令、命令组或没有 #! 行的脚本
+// 每个 builtinProcess 持有解释器的副本，与 Shell 的子进程一样互不影响
 type builtinProcess struct {
+	sh           *Interp
 	cmd          pipelineCommand
+	script       string // 非空时由 Shell 解释执行这个文件
 	stdin        io.Reader
 	stdout       io.Writer
 	stdoutCloser io.Closer
 	stderr       io.Writer
-	done         chan error
+	done         chan int
 }
 
-func newBuiltinProcess(cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stdoutCloser io.Closer, stderr io.Writer) *builtinProcess {
+func newBuiltinProcess(sh *Interp, cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stdoutCloser io.Closer, stderr io.Writer) *builtinProcess {
 	return &builtinProcess{
+		sh:           sh,
 		cmd:          cmd,
 		stdin:        stdin,
 		stdout:       stdout,
@@ -53,131 +59,77 @@ func newBuiltinProcess(cmd pipelineCommand, stdin io.Reader, stdout io.Writer, s
 }
 
 func (p *builtinProcess) Start() error {
-	p.done = make(chan error, 1)
+	p.done = make(chan int, 1)
 	go func() {
-		err := runBuiltinCommand(p.cmd, p.stdin, p.stdout, p.stderr)
+		var status int
+		if p.script != "" {
+			status = p.sh.runScript(p.script, p.cmd, p.stdin, p.stdout, p.stderr)
+		} else if p.cmd.group != nil {
+			status = p.sh.runGroup(p.cmd.group, p.stdin, p.stdout, p.stderr)
+		} else {
+			status = p.sh.runCommand(p.cmd, p.stdin, p.stdout, p.stderr)
+		}
 		if p.stdoutCloser != nil {
 			p.stdoutCloser.Close()
 		}
-		p.done <- err
+		p.done <- status
 	}()
 	return nil
 }
 
-func (p *builtinProcess) Wait() error {
+func (p *builtinProcess) Wait() int {
 	if p.done == nil {
-		return nil
+		return 0
 	}
 	return <-p.done
 }
 
-// HandlePipeline 处理管道命令
-func HandlePipeline(pipeParts []string) {
-	if len(pipeParts) < 2 {
-		return
-	}
-
-	commands := make([]pipelineCommand, len(pipeParts))
-
-	var stdoutFile, stderrFile, stdappendFile, stderrappendFile string
-
-	for i, part := range pipeParts {
-		cmdSlice := ParseCommand(part)
-		if len(cmdSlice) == 0 {
-			return
-		}
-
-		actualCmdSlice, outFile, errFile, appendFile, errAppendFile := ParseRedirect(cmdSlice)
-		if len(actualCmdSlice) == 0 {
-			return
-		}
-
-		commands[i] = pipelineCommand{
-			args:      actualCmdSlice,
-			raw:       strings.TrimSpace(part),
-			isBuiltin: isBuiltinCommand(actualCmdSlice[0]),
-		}
-
-		if i == len(pipeParts)-1 {
-			stdoutFile = outFile
-			stderrFile = errFile
-			stdappendFile = appendFile
-			stderrappendFile = errAppendFile
-		}
-	}
-
-	// 配置最终输出
-	finalStdout := io.Writer(os.Stdout)
-	var finalStdoutCloser io.Closer
-	if stdoutFile != "" {
-		outputFile, err := os.Create(stdoutFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating file %s: %v\n", stdoutFile, err)
-			return
-		}
-		finalStdout = outputFile
-		finalStdoutCloser = outputFile
-	} else if stdappendFile != "" {
-		appendFile, err := os.OpenFile(stdappendFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error opening file %s: %v\n", stdappendFile, err)
-			return
+// executePipeline 执行管道命令，返回每条命令的退出状态（即 PIPESTATUS）
+// 管道无法启动时只返回一个表示失败的状态
+func executePipeline(sh *Interp, stages []*stageNode, stdin io.Reader, stdout io.Writer, stderr io.Writer) []int {
+	commands := make([]pipelineCommand, len(stages))
+	for i, stage := range stages {
+		if stage.group != nil {
+			commands[i] = pipelineCommand{group: stage.group}
+		} else if stage.function != nil {
+			// 管道中的函数定义只在子 Shell 中生效，等同于空命令
+			commands[i] = pipelineCommand{}
+		} else {
+			cmd, err := sh.parseSimpleCommand(stage.raw)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				sh.aborting = true
+				return []int{1}
+			}
+			sh.trace(cmd, stderr)
+			commands[i] = cmd
 		}
-		finalStdout = appendFile
-		finalStdoutCloser = appendFile
 	}
 
-	finalStderr := io.Writer(os.Stderr)
-	var finalStderrCloser io.Closer
-	if stderrFile != "" {
-		errorFile, err := os.Create(stderrFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating file %s: %v\n", stderrFile, err)
-			if finalStdoutCloser != nil {
-				finalStdoutCloser.Close()
+	// 创建管道
+	pipeReaders := make([]*os.File, len(commands)-1)
+	pipeWriters := make([]*os.File, len(commands)-1)
+	var closers []io.Closer
+	closePipes := func() {
+		for j := range pipeReaders {
+			if pipeReaders[j] != nil {
+				pipeReaders[j].Close()
 			}
-			return
 		}
-		finalStderr = errorFile
-		finalStderrCloser = errorFile
-	} else if stderrappendFile != "" {
-		errAppendFile, err := os.OpenFile(stderrappendFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error opening file %s: %v\n", stderrappendFile, err)
-			if finalStdoutCloser != nil {
-				finalStdoutCloser.Close()
+		for j := range pipeWriters {
+			if pipeWriters[j] != nil {
+				pipeWriters[j].Close()
 			}
-			return
 		}
-		finalStderr = errAppendFile
-		finalStderrCloser = errAppendFile
-	}
-
-	executePipeline(commands, finalStdout, finalStderr)
-
-	if finalStdoutCloser != nil {
-		finalStdoutCloser.Close()
+		closeFiles(closers)
 	}
-	if finalStderrCloser != nil {
-		finalStderrCloser.Close()
-	}
-}
-
-// executePipeline 执行管道命令
-func executePipeline(commands []pipelineCommand, finalStdout io.Writer, finalStderr io.Writer) {
-	if len(commands) < 2 {
-		return
-	}
-
-	// 创建管道
-	pipeReaders := make([]*os.File, len(commands)-1)
-	pipeWriters := make([]*os.File, len(commands)-1)
 
 	for i := 0; i < len(commands)-1; i++ {
 		reader, writer, err := os.Pipe()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating pipe: %v\n", err)
-			return
+			fmt.Fprintf(stderr, "Error creating pipe: %v\n", err)
+			closePipes()
+			return []int{1}
 		}
 		pipeReaders[i] = reader
 		pipeWriters[i] = writer
@@ -185,75 +137,108 @@ func executePipeline(commands []pipelineCommand, finalStdout io.Writer, finalStd
 
 	processes := make([]pipelineProcess, len(commands))
 
+	// abort 在第 started 条命令无法启动时结束管道：结束已经启动的外部程序，
+	// 关闭还没有启动的命令使用的管道（前面的内置命令写入时得到错误而结束），并等待已经启动的命令，
+	// 不留下僵尸进程和仍在读写管道的 goroutine
+	abort := func(started int) {
+		for j := 0; j < started; j++ {
+			if external, ok := processes[j].(*externalProcess); ok {
+				external.cmd.Process.Kill()
+				if j < len(pipeWriters) {
+					pipeWriters[j].Close()
+				}
+			}
+		}
+		for j := max(started-1, 0); j < len(pipeReaders); j++ {
+			pipeReaders[j].Close()
+		}
+		for j := started; j < len(pipeWriters); j++ {
+			pipeWriters[j].Close()
+		}
+		for j := 0; j < started; j++ {
+			processes[j].Wait()
+		}
+		closePipes()
+	}
+
 	for i, cmdInfo := range commands {
-		var stdin io.Reader
-		if i == 0 {
-			stdin = os.Stdin
-		} else {
-			stdin = pipeReaders[i-1]
+		cmdStdin := stdin
+		if i > 0 {
+			cmdStdin = pipeReaders[i-1]
 		}
 
-		var stdout io.Writer
+		cmdStdout := stdout
 		var stdoutCloser io.Closer
-		if i == len(commands)-1 {
-			stdout = finalStdout
-		} else {
-			stdout = pipeWriters[i]
+		if i < len(commands)-1 {
+			cmdStdout = pipeWriters[i]
 			stdoutCloser = pipeWriters[i]
 		}
 
-		var stderr io.Writer
-		if i == len(commands)-1 {
-			stderr = finalStderr
-		} else {
-			stderr = os.Stderr
+		// 每条命令可以有自己的重定向，组的重定向在执行时处理
+		cmdStderr := stderr
+		if cmdInfo.group == nil {
+			cio, files, err := sh.openRedirects(cmdInfo.redirect, cmdStdin, cmdStdout, cmdStderr)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				closePipes()
+				return []int{1}
+			}
+			cmdStdin, cmdStdout, cmdStderr = cio.stdin, cio.stdout, cio.stderr
+			cmdInfo.fds = cio.extra
+			commands[i].fds = cio.extra
+			closers = append(closers, files...)
 		}
 
-		if cmdInfo.isBuiltin && (cmdInfo.args[0] == "echo" || cmdInfo.args[0] == "type") {
-			processes[i] = newBuiltinProcess(cmdInfo, stdin, stdout, stdoutCloser, stderr)
+		if cmdInfo.group != nil || cmdInfo.isBuiltin || len(cmdInfo.args) == 0 || sh.funcs[cmdInfo.args[0]] != nil {
+			processes[i] = newBuiltinProcess(sh.Clone(), cmdInfo, cmdStdin, cmdStdout, stdoutCloser, cmdStderr)
+		} else if fullPath, err := sh.lookupCommand(cmdInfo.args[0]); err != nil {
+			// 找不到命令时由 runCommand 在这一段的输入输出上报告错误或调用 command_not_found_handle，
+			// 管道中的其他命令照常执行
+			processes[i] = newBuiltinProcess(sh.Clone(), cmdInfo, cmdStdin, cmdStdout, stdoutCloser, cmdStderr)
 		} else {
-			fullPath, found := utils.FindExecutable(cmdInfo.args[0])
-			if !found {
-				fmt.Printf("%s: command not found\n", cmdInfo.args[0])
-				for j := 0; j < len(pipeReaders); j++ {
-					pipeReaders[j].Close()
-				}
-				for j := 0; j < len(pipeWriters); j++ {
-					pipeWriters[j].Close()
-				}
-				return
-			}
-			cmd := exec.Command(fullPath, cmdInfo.args[1:]...)
-			cmd.Args = append([]string{cmdInfo.args[0]}, cmdInfo.args[1:]...)
-			cmd.Stdin = stdin
-			cmd.Stdout = stdout
-			cmd.Stderr = stderr
+			sh.commands.recordUse(cmdInfo.args[0])
+			cmd := sh.newExternalCmd(fullPath, cmdInfo, cmdStdin, cmdStdout, cmdStderr)
 			processes[i] = &externalProcess{cmd: cmd}
 		}
 	}
 
-	for _, proc := range processes {
-		if err := proc.Start(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error starting command: %v\n", err)
-			for j := range pipeReaders {
-				pipeReaders[j].Close()
-			}
-			for j := range pipeWriters {
-				pipeWriters[j].Close()
+	for i, proc := range processes {
+		err := proc.Start()
+		if external, ok := proc.(*externalProcess); ok && isExecFormatError(err) {
+			// 没有 #! 行的脚本改为在 goroutine 中由 Shell 解释执行
+			var stdoutCloser io.Closer
+			if i < len(pipeWriters) {
+				stdoutCloser = pipeWriters[i]
 			}
-			return
+			script := newBuiltinProcess(sh.Clone(), commands[i], external.cmd.Stdin, external.cmd.Stdout, stdoutCloser, external.cmd.Stderr)
+			script.script = external.cmd.Path
+			processes[i] = script
+			err = script.Start()
+		}
+		if err != nil {
+			// 先等待已经启动的命令结束，它们可能与这里共用同一个标准错误
+			abort(i)
+			fmt.Fprintf(stderr, "Error starting command: %v\n", err)
+			return []int{1}
 		}
 	}
 
+	// 外部进程已经继承了管道写端，父进程需要关闭自己的副本；
+	// 内置命令的写端由 builtinProcess 在执行结束后关闭
 	for i := range pipeWriters {
-		pipeWriters[i].Close()
+		if _, ok := processes[i].(*externalProcess); ok {
+			pipeWriters[i].Close()
+		}
 	}
 
-	for _, proc := range processes {
-		proc.Wait()
+	statuses := make([]int, len(processes))
+	for i, proc := range processes {
+		statuses[i] = proc.Wait()
 	}
 
 	for i := 0; i < len(pipeReaders); i++ {
 		pipeReaders[i].Close()
 	}
+	closeFiles(closers)
+	return statuses
 }
@@ -0,0 +1,42 @@
+//go:build unix
+
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// 管道中的命令无法启动时，前面已经启动的命令被结束并回收，不留下僵尸进程
+func TestPipelineStartFailure(t *testing.T) {
+	dir := t.TempDir()
+	// 解释器不存在，execve 返回 ENOENT
+	if err := os.WriteFile(filepath.Join(dir, "bad"), []byte("#!/nonexistent/interpreter\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sh := NewInterp()
+	sh.dir = dir
+	start := time.Now()
+	got := runTest(t, sh, "sleep 5 | ./bad; echo $?; echo x | cat | ./bad; echo $?")
+	if got != "1\n1\n" {
+		t.Errorf("got %q, want %q", got, "1\n1\n")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("pipeline waited %v for the started commands", elapsed)
+	}
+	var status syscall.WaitStatus
+	if pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil); err != syscall.ECHILD {
+		t.Errorf("child process left behind: pid %d, err %v", pid, err)
+	}
+}
+
+// 被信号结束的命令的退出状态为 128 加信号编号，PIPESTATUS 中也是如此
+func TestSignaledExitStatus(t *testing.T) {
+	got := runTest(t, NewInterp(), "sh -c 'kill -TERM $$'; echo $?; sh -c 'kill -KILL $$' | true; echo ${PIPESTATUS[0]}")
+	if want := "143\n137\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
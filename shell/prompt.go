@@ -0,0 +1,213 @@
+package shell
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 各提示符变量未设置时的默认值
+var defaultPrompts = map[string]string{
+	"PS1": "$ ", // 主提示符
+	"PS2": "> ", // 命令未输入完整时的续行提示符
+	"PS4": "+ ", // set -x 跟踪输出的前缀
+}
+
+// promptValue 返回提示符变量的值，未设置时使用默认值
+func (sh *Interp) promptValue(name string) string {
+	if value, ok := sh.vars[name]; ok {
+		return value
+	}
+	return defaultPrompts[name]
+}
+
+// RenderPrompt 渲染 PS1/PS2 等提示符，在每次调用 rl.Readline() 之前执行
+// readline 只能正确计算单行提示符中 ANSI 颜色序列的宽度，因此结果分为两部分：
+// prefix 需要在读取输入之前直接输出（多行提示符的前几行以及 \[ \] 中的其他控制序列），
+// line 交给 readline 作为提示符
+func (sh *Interp) RenderPrompt(name string) (prefix string, line string) {
+	rendered, hidden := sh.expandPrompt(sh.promptValue(name))
+	if idx := strings.LastIndexByte(rendered, '\n'); idx != -1 {
+		prefix, rendered = rendered[:idx+1], rendered[idx+1:]
+	}
+	sh.promptLine = rendered
+	sh.rightPrompt = ""
+	if name == "PS1" {
+		sh.drawnPrompts = nil
+		// 右侧提示符只跟随主提示符显示
+		if rprompt, rhidden := sh.expandPrompt(sh.getVar("RPROMPT")); rprompt != "" {
+			sh.rightPrompt = rhidden + rprompt
+		}
+	}
+	sh.drawnPrompts = append(sh.drawnPrompts, prefix+rendered)
+	return hidden + prefix, rendered
+}
+
+// expandPrompt 展开提示符中的反斜杠转义和变量，返回展开后的文本，
+// 以及 \[ \] 中 readline 无法识别为颜色序列的不可打印内容
+//
+//	\u 用户名    \h 主机名（第一个 . 之前）  \H 完整主机名
+//	\w 当前目录  \W 当前目录的最后一级      \$ root 为 #，否则为 $
+//	\t HH:MM:SS  \T 12 小时制 HH:MM:SS      \@ 12 小时制 am/pm  \A HH:MM  \d 日期
+//	\j 后台任务数 \? 上一条命令的退出状态   \s Shell 名称
+//	\g Git 分支和状态标记（直接读取 .git，不调用 git 命令）
+//	\n 换行  \r 回车  \a 响铃  \e ESC  \nnn 八进制字符  \\ 反斜杠
+//	\[ \] 包围不可打印的字符序列（如颜色），不计入提示符宽度
+//
+// 变量在转义之后展开，转义产生的文本（例如包含 $ 的目录名）不再展开
+func (sh *Interp) expandPrompt(ps string) (string, string) {
+	var result strings.Builder
+	var hidden strings.Builder
+	var nonPrinting *strings.Builder // 位于 \[ 和 \] 之间时不为空
+	now := time.Now()
+
+	// 含有 $、引号或反斜杠的文本先用占位符代替，展开变量之后再换回来
+	var literals []string
+	literal := func(out *strings.Builder, text string) {
+		if !strings.ContainsAny(text, "$`'\"\\") {
+			out.WriteString(text)
+			return
+		}
+		out.WriteString(promptPlaceholder + strconv.Itoa(len(literals)) + promptPlaceholder)
+		literals = append(literals, text)
+	}
+
+	for i := 0; i < len(ps); i++ {
+		out := &result
+		if nonPrinting != nil {
+			out = nonPrinting
+		}
+		if ps[i] != '\\' || i+1 >= len(ps) {
+			out.WriteByte(ps[i])
+			continue
+		}
+		i++
+		switch ps[i] {
+		case 'u':
+			literal(out, sh.userName())
+		case 'h', 'H':
+			host, _ := os.Hostname()
+			if ps[i] == 'h' {
+				host, _, _ = strings.Cut(host, ".")
+			}
+			literal(out, host)
+		case 'w':
+			literal(out, sh.abbreviateHome(sh.dir))
+		case 'W':
+			dir := sh.abbreviateHome(sh.dir)
+			if dir != "~" && dir != "/" {
+				dir = filepath.Base(dir)
+			}
+			literal(out, dir)
+		case '$':
+			if os.Geteuid() == 0 {
+				out.WriteByte('#')
+			} else {
+				literal(out, "$")
+			}
+		case 't':
+			out.WriteString(now.Format("15:04:05"))
+		case 'T':
+			out.WriteString(now.Format("03:04:05"))
+		case '@':
+			out.WriteString(now.Format("03:04 PM"))
+		case 'A':
+			out.WriteString(now.Format("15:04"))
+		case 'd':
+			out.WriteString(now.Format("Mon Jan 02"))
+		case 'j':
+			// 目前没有作业控制，后台任务数总是 0
+			out.WriteByte('0')
+		case '?':
+			out.WriteString(strconv.Itoa(sh.status))
+		case 'g':
+			literal(out, sh.gitPromptSegment())
+		case 's':
+			literal(out, filepath.Base(os.Args[0]))
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 'a':
+			out.WriteByte('\a')
+		case 'e':
+			out.WriteByte('\033')
+		case '\\':
+			literal(out, "\\")
+		case '[':
+			nonPrinting = &strings.Builder{}
+		case ']':
+			if nonPrinting == nil {
+				continue
+			}
+			// readline 会忽略 ESC [ ... m 形式的颜色序列，其他控制序列需要提前输出
+			if seq := nonPrinting.String(); isColorSequence(seq) {
+				result.WriteString(seq)
+			} else {
+				hidden.WriteString(seq)
+			}
+			nonPrinting = nil
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i + 1
+			for end < len(ps) && end < i+3 && ps[end] >= '0' && ps[end] <= '7' {
+				end++
+			}
+			code, _ := strconv.ParseUint(ps[i:end], 8, 8)
+			out.WriteByte(byte(code))
+			i = end - 1
+		default:
+			out.WriteByte('\\')
+			out.WriteByte(ps[i])
+		}
+	}
+	if nonPrinting != nil {
+		result.WriteString(nonPrinting.String())
+	}
+	return restoreLiterals(sh.expandVariables(result.String()), literals), restoreLiterals(hidden.String(), literals)
+}
+
+// promptPlaceholder 包围转义产生的文本在 literals 中的下标，使用私有区字符，不会出现在普通文本中
+const promptPlaceholder = "\uE000"
+
+// restoreLiterals 把占位符换回转义产生的文本
+func restoreLiterals(s string, literals []string) string {
+	if len(literals) == 0 {
+		return s
+	}
+	parts := strings.Split(s, promptPlaceholder)
+	for i := 1; i < len(parts); i += 2 {
+		if n, err := strconv.Atoi(parts[i]); err == nil && n < len(literals) {
+			parts[i] = literals[n]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// isColorSequence 判断 seq 是否只由 ESC [ 数字;数字 m 形式的颜色序列组成
+func isColorSequence(seq string) bool {
+	for seq != "" {
+		if !strings.HasPrefix(seq, "\033[") {
+			return false
+		}
+		end := strings.IndexByte(seq, 'm')
+		if end == -1 || strings.Trim(seq[2:end], "0123456789;") != "" {
+			return false
+		}
+		seq = seq[end+1:]
+	}
+	return true
+}
+
+// userName 返回当前用户名，优先使用 USER 变量
+func (sh *Interp) userName() string {
+	if name := sh.getVar("USER"); name != "" {
+		return name
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// \w、\W 产生的目录名不再展开变量，其中的 $ 和引号原样显示
+func TestPromptEscapesNotExpandedTwice(t *testing.T) {
+	home := t.TempDir()
+	dir := filepath.Join(home, "play", "$HOME", "it's")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sh := NewInterp()
+	sh.setVar("HOME", home)
+	sh.setVar("NAME", "x")
+	sh.dir = dir
+
+	tests := []struct {
+		ps   string
+		want string
+	}{
+		{`\w`, "~/play/$HOME/it's"},
+		{`\W`, "it's"},
+		{`[\W] $NAME`, "[it's] x"},
+		{`$NAME\\$NAME`, `x\x`},
+	}
+	for _, tt := range tests {
+		if got, _ := sh.expandPrompt(tt.ps); got != tt.want {
+			t.Errorf("expandPrompt(%q) = %q, want %q", tt.ps, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// redirectOp 是一个重定向操作，按照在命令中出现的顺序依次执行
+//
+//	[n]>file  [n]>>file  [n]<file   打开文件
+//	[n]>|file                       即使开启 noclobber 也覆盖文件
+//	[n]>&m    [n]<&m                复制文件描述符 m
+//	[n]>&-    [n]<&-                关闭文件描述符
+//	&>file    &>>file               同时重定向标准输出和标准错误
+type redirectOp struct {
+	fd     int    // 被重定向的文件描述符，-1 表示 &> 的标准输出和标准错误
+	op     string // ">"、">|"、">>"、"<"、">&"、"<&"
+	target string // 文件名，或者复制的目标描述符，"-" 表示关闭
+	text   string // 重定向在命令中的原始文本，echo 需要从原始命令中移除
+}
+
+// redirectSpec 保存一条命令中解析出的所有重定向
+type redirectSpec struct {
+	ops []redirectOp
+}
+
+// redirectOperators 按长度从长到短排列，保证优先匹配较长的操作符
+var redirectOperators = []string{"&>>", "&>", ">>", ">&", ">|", "<&", ">", "<"}
+
+// parseRedirectSpec 解析重定向操作符，返回去掉重定向后的命令部分
+func parseRedirectSpec(cmdSlice []string) ([]string, redirectSpec) {
+	var args []string
+	var spec redirectSpec
+	for i := 0; i < len(cmdSlice); i++ {
+		op, ok := parseRedirectWord(cmdSlice[i])
+		if !ok {
+			args = append(args, cmdSlice[i])
+			continue
+		}
+		// 操作符和目标之间可以有空格，例如 "> out"
+		if op.target == "" && i+1 < len(cmdSlice) {
+			i++
+			op.target = cmdSlice[i]
+			op.text += " " + cmdSlice[i]
+		}
+		if op.target == "" {
+			args = append(args, cmdSlice[i])
+			continue
+		}
+		// >&file 不是复制描述符时等价于 &>file
+		if op.op == ">&" && op.target != "-" && !isNumber(op.target) && op.fd == 1 && !strings.HasPrefix(op.text, "1") {
+			op.fd, op.op = -1, ">"
+		}
+		spec.ops = append(spec.ops, op)
+	}
+	return args, spec
+}
+
+// parseRedirectWord 判断单词是否以重定向操作符开头（前面可以有描述符编号）
+func parseRedirectWord(word string) (redirectOp, bool) {
+	digits := 0
+	for digits < len(word) && word[digits] >= '0' && word[digits] <= '9' {
+		digits++
+	}
+	rest := word[digits:]
+	for _, operator := range redirectOperators {
+		if !strings.HasPrefix(rest, operator) {
+			continue
+		}
+		if operator[0] == '&' && digits > 0 {
+			return redirectOp{}, false
+		}
+		op := redirectOp{op: operator, target: rest[len(operator):], text: word}
+		switch {
+		case operator == "&>" || operator == "&>>":
+			op.fd, op.op = -1, operator[1:]
+		case digits > 0:
+			op.fd, _ = strconv.Atoi(word[:digits])
+		case operator[0] == '<':
+			op.fd = 0
+		default:
+			op.fd = 1
+		}
+		return op, true
+	}
+	return redirectOp{}, false
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil && !strings.HasPrefix(s, "-")
+}
+
+// commandIO 是一条命令的标准输入、输出、错误，以及 3 及以上的文件描述符
+type commandIO struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	extra  map[int]*os.File // 传递给外部程序的其他文件描述符
+}
+
+// get 返回描述符 fd 当前对应的读写对象
+func (cio *commandIO) get(fd int) (any, bool) {
+	switch fd {
+	case 0:
+		return cio.stdin, cio.stdin != nil
+	case 1:
+		return cio.stdout, cio.stdout != nil
+	case 2:
+		return cio.stderr, cio.stderr != nil
+	}
+	f, ok := cio.extra[fd]
+	return f, ok
+}
+
+// set 将描述符 fd 指向 v，v 为 nil 时关闭该描述符
+// 关闭的标准输出和标准错误丢弃写入的内容
+func (cio *commandIO) set(fd int, v any) {
+	switch fd {
+	case 0:
+		cio.stdin, _ = v.(io.Reader)
+	case 1, 2:
+		w, ok := v.(io.Writer)
+		if !ok {
+			w = io.Discard
+		}
+		if fd == 1 {
+			cio.stdout = w
+		} else {
+			cio.stderr = w
+		}
+	default:
+		if f, ok := v.(*os.File); ok {
+			cio.extra[fd] = f
+		} else {
+			delete(cio.extra, fd)
+		}
+	}
+}
+
+// openRedirects 按照重定向配置打开文件，返回替换后的标准输入输出以及需要关闭的文件
+// 没有对应重定向时沿用传入的读写对象，3 及以上的描述符从 Shell 的描述符表继承
+// 相对路径相对于解释器的工作目录
+func (sh *Interp) openRedirects(spec redirectSpec, stdin io.Reader, stdout io.Writer, stderr io.Writer) (*commandIO, []io.Closer, error) {
+	cio := &commandIO{stdin: stdin, stdout: stdout, stderr: stderr, extra: make(map[int]*os.File)}
+	for fd, f := range sh.fds {
+		if fd > 2 {
+			cio.extra[fd] = f
+		}
+	}
+
+	var closers []io.Closer
+	for _, op := range spec.ops {
+		switch op.op {
+		case ">&", "<&":
+			if op.target == "-" {
+				cio.set(op.fd, nil)
+				continue
+			}
+			source, _ := strconv.Atoi(op.target)
+			v, ok := cio.get(source)
+			if !ok || !isNumber(op.target) {
+				closeFiles(closers)
+				return nil, nil, fmt.Errorf("%s: Bad file descriptor", op.target)
+			}
+			cio.set(op.fd, v)
+		default:
+			file, err := sh.openRedirectFile(op)
+			if err != nil {
+				closeFiles(closers)
+				return nil, nil, err
+			}
+			closers = append(closers, file)
+			if op.fd == -1 {
+				cio.set(1, file)
+				cio.set(2, file)
+			} else {
+				cio.set(op.fd, file)
+			}
+		}
+	}
+	return cio, closers, nil
+}
+
+// openRedirectFile 打开 >、>|、>>、< 重定向的文件
+func (sh *Interp) openRedirectFile(op redirectOp) (*os.File, error) {
+	switch op.op {
+	case ">>":
+		file, err := os.OpenFile(sh.resolvePath(op.target), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening file %s: %v", op.target, err)
+		}
+		return file, nil
+	case "<":
+		file, err := os.Open(sh.resolvePath(op.target))
+		if err != nil {
+			return nil, fmt.Errorf("%s: No such file or directory", op.target)
+		}
+		return file, nil
+	}
+	return sh.createFile(op.target, op.op == ">|")
+}
+
+// createFile 为 > 重定向创建或清空文件，force 为 true 时（>|）忽略 noclobber
+// 开启 noclobber 时用 O_EXCL 创建文件，检查和创建之间不会被其他进程抢先；
+// 已存在的普通文件不会被覆盖，/dev/null 等特殊文件照常打开（不截断）
+func (sh *Interp) createFile(name string, force bool) (*os.File, error) {
+	path := sh.resolvePath(name)
+	if !sh.options["noclobber"] || force {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating file %s: %v", name, err)
+		}
+		return file, nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err == nil {
+		return file, nil
+	}
+	if !errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("Error creating file %s: %v", name, err)
+	}
+	if info, statErr := os.Stat(path); statErr != nil || info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s: cannot overwrite existing file", name)
+	}
+	file, err = os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating file %s: %v", name, err)
+	}
+	return file, nil
+}
+
+// closeFiles 关闭 openRedirects 打开的文件
+func closeFiles(closers []io.Closer) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+// stripRedirects 从原始命令文本中移除重定向部分
+// echo 直接处理原始文本（以保留引号内的空白），因此需要先去掉重定向
+func stripRedirects(raw string, spec redirectSpec) string {
+	cmd := raw
+	for i := len(spec.ops) - 1; i >= 0; i-- {
+		pattern := " " + spec.ops[i].text
+		if idx := strings.LastIndex(cmd, pattern); idx != -1 {
+			cmd = strings.TrimRight(cmd[:idx], " ") + cmd[idx+len(pattern):]
+		}
+	}
+	return cmd
+}
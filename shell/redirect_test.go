@@ -0,0 +1,26 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// set -C 时 > 不覆盖已存在的普通文件，>| 强制覆盖，/dev/null 等特殊文件不受影响
+func TestNoclobber(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "out.txt")
+	sh := NewInterp()
+	sh.dir = dir
+	got := runTest(t, sh, "set -C; echo a > out.txt; echo $?; echo b > out.txt; echo $?; echo c >/dev/null; echo $?")
+	if got != "0\n1\n0\n" {
+		t.Errorf("statuses = %q", got)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "a\n" {
+		t.Errorf("after >: %q, want %q", data, "a\n")
+	}
+	runTest(t, sh, "echo d >| out.txt")
+	if data, _ := os.ReadFile(file); string(data) != "d\n" {
+		t.Errorf("after >|: %q, want %q", data, "d\n")
+	}
+}
@@ -0,0 +1,101 @@
+package shell
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/chzyer/readline/runes"
+)
+
+// PromptPainter 在 readline 绘制输入行时把 RPROMPT 右对齐显示在同一行，
+// 输入过长会与右侧提示符重叠时不显示；补全菜单打开时绘制在输入行下方
+type PromptPainter struct {
+	sh *Interp
+}
+
+// NewPromptPainter 创建绘制右侧提示符的 readline.Painter
+func NewPromptPainter(sh *Interp) readline.Painter {
+	return &PromptPainter{sh: sh}
+}
+
+// Paint 实现 readline.Painter，返回实际输出到终端的内容：输入行、右侧提示符以及补全菜单
+func (p *PromptPainter) Paint(line []rune, pos int) []rune {
+	return p.paintMenu(p.paintRightPrompt(line), line)
+}
+
+// paintRightPrompt 在输入行右侧绘制 RPROMPT
+// readline 会根据 line 的长度计算光标位置，所以右侧提示符用保存/恢复光标包围，
+// 输出后光标仍停在输入内容的末尾
+func (p *PromptPainter) paintRightPrompt(line []rune) []rune {
+	rprompt := p.sh.rightPrompt
+	if rprompt == "" || strings.ContainsRune(rprompt, '\n') {
+		return line
+	}
+	width := readline.GetScreenWidth()
+	if width <= 0 {
+		return line
+	}
+	rightWidth := displayWidth(rprompt)
+	used := displayWidth(p.sh.promptLine) + runes.WidthAll(line)
+	// 至少留一个空格分隔，并且不占用最后一列，避免终端自动换行
+	if strings.ContainsRune(string(line), '\n') || used+1+rightWidth >= width {
+		return line
+	}
+	column := width - rightWidth
+	seq := "\0337\033[" + strconv.Itoa(column) + "G" + rprompt + "\0338"
+	return append(append([]rune{}, line...), []rune(seq)...)
+}
+
+// displayWidth 返回字符串在终端上的显示宽度，忽略颜色序列
+func displayWidth(s string) int {
+	return runes.WidthAll(runes.ColorFilter([]rune(s)))
+}
+
+// promptRows 返回提示符和输入内容在终端上占用的行数（不含 readline 在行尾输出的换行）
+func promptRows(prompt string, input string) int {
+	width := readline.GetScreenWidth()
+	if width <= 0 {
+		return strings.Count(prompt+input, "\n") + 1
+	}
+	rows := 0
+	for _, line := range strings.Split(prompt+input, "\n") {
+		rows += displayWidth(line)/width + 1
+	}
+	return rows
+}
+
+// TransientPrompt 实现瞬态提示符：设置了 TRANSIENT_PROMPT 时，命令输入完成后
+// 把屏幕上刚才的提示符（包括多行提示符的前几行和 PS2 续行）擦除，
+// 改用 TRANSIENT_PROMPT 展开后的紧凑形式重绘，再开始输出命令结果
+// lines 是这条命令依次读取的各行输入，返回需要输出到终端的内容
+func (sh *Interp) TransientPrompt(lines []string) string {
+	compact, ok := sh.vars["TRANSIENT_PROMPT"]
+	if !ok || len(lines) != len(sh.drawnPrompts) || !readline.DefaultIsTerminal() {
+		return ""
+	}
+	rows := 0
+	for i, line := range lines {
+		rows += promptRows(sh.drawnPrompts[i], line)
+	}
+
+	var out strings.Builder
+	// 光标位于最后一行输入之后的新行，向上移动到第一行提示符处并清除之后的内容
+	out.WriteString("\033[" + strconv.Itoa(rows) + "A\r\033[J")
+	first, hidden := sh.expandPrompt(compact)
+	out.WriteString(hidden)
+	continuation, _ := sh.expandPrompt(sh.promptValue("PS2"))
+	if idx := strings.LastIndexByte(continuation, '\n'); idx != -1 {
+		continuation = continuation[idx+1:]
+	}
+	for i, line := range lines {
+		if i == 0 {
+			out.WriteString(first)
+		} else {
+			out.WriteString(continuation)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
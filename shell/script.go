@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// isExecFormatError 判断启动外部程序失败是否是因为文件不是可识别的可执行格式（ENOEXEC），
+// 例如没有 #! 行的脚本
+func isExecFormatError(err error) bool {
+	return errors.Is(err, syscall.ENOEXEC)
+}
+
+// runScript 在子 Shell 中解释执行内核无法执行的文件，与 POSIX Shell 一样把它当作 Shell 脚本
+// 命令的参数作为位置参数，$0 为命令名；看起来是二进制文件时报告无法执行
+func (sh *Interp) runScript(path string, cmd pipelineCommand, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.args[0], err)
+		return 126
+	}
+	if isBinaryFile(data) {
+		fmt.Fprintf(stderr, "%s: cannot execute binary file: Exec format error\n", cmd.args[0])
+		return 126
+	}
+	list, err := parseList(string(data))
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", cmd.args[0], err)
+		return 2
+	}
+
+	sub := sh.scriptShell()
+	sub.name = cmd.args[0]
+	sub.args = cmd.args[1:]
+	sub.status = 0
+	for _, assign := range cmd.assigns {
+		name, value, _ := strings.Cut(assign, "=")
+		sub.exportVar(name, value)
+	}
+	for fd, f := range cmd.fds {
+		sub.fds[fd] = f
+	}
+	sub.runList(list, stdin, stdout, stderr)
+	return sub.runExitTrap(stdin, stdout, stderr)
+}
+
+// scriptShell 创建执行脚本的子 Shell，效果与启动一个新的 Shell 相同：
+// 只保留导出的变量、工作目录和 hash 表，函数、选项和 trap 都被重置
+func (sh *Interp) scriptShell() *Interp {
+	sub := sh.Clone()
+	for name := range sub.vars {
+		if !sub.exported[name] {
+			delete(sub.vars, name)
+		}
+	}
+	sub.dirStack = nil
+	sub.funcs = make(map[string]*groupNode)
+	sub.options = make(map[string]bool)
+	sub.traps = make(map[string]string)
+	sub.funcDepth = 0
+	sub.conditionDepth = 0
+	sub.pipeStatus = nil
+	sub.inTrap = false
+	return sub
+}
+
+// isBinaryFile 与 bash 一样，文件第一行（最多 80 字节）中有 NUL 字符时认为是二进制文件
+func isBinaryFile(data []byte) bool {
+	sample := data[:min(len(data), 80)]
+	if i := bytes.IndexByte(sample, '\n'); i != -1 {
+		sample = sample[:i]
+	}
+	return bytes.IndexByte(sample, 0) != -1
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package shell
+
+import "syscall"
+
+func init() {
+	trapSignals["STOP"] = syscall.SIGSTOP
+	trapSignals["USR1"] = syscall.SIGUSR1
+	trapSignals["USR2"] = syscall.SIGUSR2
+	trapSignals["CHLD"] = syscall.SIGCHLD
+	trapSignals["CONT"] = syscall.SIGCONT
+	trapSignals["TSTP"] = syscall.SIGTSTP
+	trapSignals["WINCH"] = syscall.SIGWINCH
+}
@@ -0,0 +1,269 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// trapSignals 是 trap 可以捕获的信号，键为不带 SIG 前缀的信号名
+// 各平台特有的信号在 signals_unix.go 中补充
+var trapSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"ABRT": syscall.SIGABRT,
+	"KILL": syscall.SIGKILL,
+	"PIPE": syscall.SIGPIPE,
+	"ALRM": syscall.SIGALRM,
+	"TERM": syscall.SIGTERM,
+}
+
+// untrappableSignals 是无法捕获或忽略的信号，只能出现在 trap -l 和 trap - 中
+var untrappableSignals = map[string]bool{"KILL": true, "STOP": true}
+
+// trapConditions 是不对应真实信号的 trap 条件
+//
+//	EXIT   Shell 退出时（正常结束、exit、EOF）
+//	ERR    命令失败时，触发条件与 set -e 相同
+//	DEBUG  每条简单命令执行之前
+//	RETURN 函数或 source 的脚本执行结束时
+var trapConditions = []string{"EXIT", "ERR", "DEBUG", "RETURN"}
+
+// trapOrder 返回 trap -p 输出时的排序键：与 bash 一样 EXIT（0）在最前，然后按信号编号，最后是 DEBUG、ERR、RETURN
+func trapOrder(name string) int {
+	if sig, ok := trapSignals[name]; ok {
+		return int(sig)
+	}
+	switch name {
+	case "EXIT":
+		return 0
+	case "DEBUG":
+		return 1000
+	case "ERR":
+		return 1001
+	}
+	return 1002
+}
+
+// parseTrapSpec 将 trap 的信号参数（INT、SIGINT、2、EXIT、0 等）转换为统一的名称
+func parseTrapSpec(spec string) (string, bool) {
+	name := strings.TrimPrefix(strings.ToUpper(spec), "SIG")
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n == 0 {
+			return "EXIT", true
+		}
+		for name, sig := range trapSignals {
+			if int(sig) == n {
+				return name, true
+			}
+		}
+		return "", false
+	}
+	if _, ok := trapSignals[name]; ok {
+		return name, true
+	}
+	for _, cond := range trapConditions {
+		if name == cond {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// signalName 返回信号对应的 trap 名称
+func signalName(sig os.Signal) string {
+	for name, s := range trapSignals {
+		if s == sig {
+			return name
+		}
+	}
+	return ""
+}
+
+// 处理 trap 命令
+//
+//	trap 'cmd' SIG...   收到信号或满足条件时执行 cmd
+//	trap '' SIG...      忽略信号
+//	trap - SIG...       恢复默认处理（只给出一个信号时可以省略 -）
+//	trap [-p] [SIG...]  以可重新执行的形式输出已设置的 trap
+//	trap -l             列出信号名称和编号
+func runTrapBuiltin(sh *Interp, cmdSlice []string, writer io.Writer, errorWriter io.Writer) int {
+	args := cmdSlice[1:]
+	printTraps := len(args) == 0
+	if len(args) > 0 {
+		switch args[0] {
+		case "-l":
+			printSignalList(writer)
+			return 0
+		case "-p":
+			printTraps = true
+			args = args[1:]
+		case "--":
+			args = args[1:]
+		}
+	}
+
+	if printTraps {
+		names := args
+		if len(names) == 0 {
+			for name := range sh.traps {
+				names = append(names, name)
+			}
+			sort.Slice(names, func(i, j int) bool {
+				return trapOrder(names[i]) < trapOrder(names[j])
+			})
+		}
+		status := 0
+		for _, spec := range names {
+			name, ok := parseTrapSpec(spec)
+			if !ok {
+				fmt.Fprintf(errorWriter, "trap: %s: invalid signal specification\n", spec)
+				status = 1
+				continue
+			}
+			if action, ok := sh.traps[name]; ok {
+				fmt.Fprintf(writer, "trap -- %s %s\n", shellQuote(action), trapDisplayName(name))
+			}
+		}
+		return status
+	}
+
+	// 只有一个参数时该参数是要恢复默认处理的信号
+	action, reset := "", true
+	if len(args) > 1 {
+		action, args = args[0], args[1:]
+		reset = action == "-"
+	}
+	status := 0
+	for _, spec := range args {
+		name, ok := parseTrapSpec(spec)
+		if !ok {
+			fmt.Fprintf(errorWriter, "trap: %s: invalid signal specification\n", spec)
+			status = 1
+			continue
+		}
+		if untrappableSignals[name] && !reset {
+			fmt.Fprintf(errorWriter, "trap: %s: cannot trap or ignore SIG%s\n", spec, name)
+			status = 1
+			continue
+		}
+		sh.setTrap(name, action, reset)
+	}
+	return status
+}
+
+// trapDisplayName 返回 trap -p 输出中使用的名称，真实信号带 SIG 前缀
+func trapDisplayName(name string) string {
+	if _, ok := trapSignals[name]; ok {
+		return "SIG" + name
+	}
+	return name
+}
+
+// printSignalList 按编号列出信号，每行 5 个
+func printSignalList(writer io.Writer) {
+	names := make([]string, 0, len(trapSignals))
+	for name := range trapSignals {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return trapSignals[names[i]] < trapSignals[names[j]]
+	})
+	for i, name := range names {
+		fmt.Fprintf(writer, "%2d) SIG%-8s", int(trapSignals[name]), name)
+		if i%5 == 4 || i == len(names)-1 {
+			fmt.Fprintln(writer)
+		} else {
+			fmt.Fprint(writer, "\t")
+		}
+	}
+}
+
+// setTrap 设置或清除 trap，真实信号同时更新 os/signal 的通知
+// 信号只投递给顶层 Shell，由 runPendingTraps 在命令之间处理
+func (sh *Interp) setTrap(name string, action string, reset bool) {
+	if reset {
+		delete(sh.traps, name)
+	} else {
+		sh.traps[name] = action
+	}
+	sig, ok := trapSignals[name]
+	if !ok || sh.signals == nil {
+		return
+	}
+	switch {
+	case reset:
+		signal.Reset(sig)
+	case action == "":
+		signal.Ignore(sig)
+	default:
+		signal.Notify(sh.signals, sig)
+	}
+}
+
+// runPendingTraps 执行已经收到的信号对应的 trap
+// 外部命令运行期间收到的信号会在命令结束后处理
+func (sh *Interp) runPendingTraps() {
+	if sh.signals == nil {
+		return
+	}
+	for {
+		select {
+		case sig := <-sh.signals:
+			if action := sh.traps[signalName(sig)]; action != "" {
+				stdin, stdout, stderr := sh.stdio()
+				sh.runTrap(action, stdin, stdout, stderr)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// runConditionTrap 执行 ERR、DEBUG、RETURN 的 trap，trap 的命令中不会再次触发
+func (sh *Interp) runConditionTrap(name string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	if action := sh.traps[name]; action != "" && !sh.inTrap {
+		sh.runTrap(action, stdin, stdout, stderr)
+	}
+}
+
+// runTrap 执行 trap 的命令，执行完成后恢复 $?（除非其中执行了 exit）
+func (sh *Interp) runTrap(action string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	list, err := parseList(action)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return
+	}
+	status, inTrap := sh.status, sh.inTrap
+	sh.inTrap = true
+	sh.runList(list, stdin, stdout, stderr)
+	sh.inTrap = inTrap
+	if !sh.exited {
+		sh.status = status
+	}
+}
+
+// RunExitTrap 在 Shell 退出之前执行 EXIT trap（只执行一次），返回 Shell 的退出状态
+func (sh *Interp) RunExitTrap() int {
+	stdin, stdout, stderr := sh.stdio()
+	return sh.runExitTrap(stdin, stdout, stderr)
+}
+
+func (sh *Interp) runExitTrap(stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	action, ok := sh.traps["EXIT"]
+	if !ok || action == "" {
+		return sh.status
+	}
+	delete(sh.traps, "EXIT")
+	exited := sh.exited
+	sh.exited, sh.returning, sh.aborting = false, false, false
+	sh.runTrap(action, stdin, stdout, stderr)
+	sh.exited = sh.exited || exited
+	return sh.status
+}
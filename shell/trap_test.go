@@ -0,0 +1,21 @@
+package shell
+
+import "testing"
+
+// KILL 和 STOP 无法捕获，trap 报告错误并返回 1，不记录 trap
+func TestTrapUntrappable(t *testing.T) {
+	sh := NewInterp()
+	got := runTest(t, sh, "trap 'echo x' KILL; echo $?; trap 'echo x' 9 TERM; echo $?; trap -p; trap - TERM")
+	if want := "1\n1\ntrap -- 'echo x' SIGTERM\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// trap -p 按信号编号输出，EXIT 在最前
+func TestTrapPrintOrder(t *testing.T) {
+	sh := NewInterp()
+	got := runTest(t, sh, "trap a TERM; trap b INT; trap c EXIT; trap d HUP; trap -p; trap - TERM INT HUP EXIT")
+	if want := "trap -- c EXIT\ntrap -- d SIGHUP\ntrap -- b SIGINT\ntrap -- a SIGTERM\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
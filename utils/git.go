@@ -0,0 +1,664 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitRepo 描述一个 Git 工作区，所有信息都直接从 .git 目录读取，不调用 git 命令
+type GitRepo struct {
+	Root      string // 工作区根目录
+	GitDir    string // HEAD 和 index 所在目录（worktree 中为 .git/worktrees/<name>）
+	CommonDir string // objects、refs、config 所在目录
+}
+
+// GitStatus 是提示符需要的仓库状态
+type GitStatus struct {
+	Branch   string // 当前分支，处于分离 HEAD 时为空
+	Head     string // HEAD 指向的提交，空仓库时为空
+	Unstaged bool   // 工作区中有未暂存的修改
+	Staged   bool   // 暂存区与 HEAD 不同
+	Ahead    int    // 本地分支领先上游的提交数
+	Behind   int    // 本地分支落后上游的提交数
+}
+
+// FindGitRepo 从 dir 开始向上查找包含 .git 的目录
+func FindGitRepo(dir string) (*GitRepo, bool) {
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			if info.IsDir() {
+				return &GitRepo{Root: dir, GitDir: dotGit, CommonDir: dotGit}, true
+			}
+			// worktree 和子模块中的 .git 是一个文件，内容为 "gitdir: <path>"
+			if repo, ok := readGitFile(dir, dotGit); ok {
+				return repo, true
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, false
+		}
+		dir = parent
+	}
+}
+
+func readGitFile(root string, dotGit string) (*GitRepo, bool) {
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return nil, false
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return nil, false
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	repo := &GitRepo{Root: root, GitDir: gitDir, CommonDir: gitDir}
+	// 多个 worktree 共享的目录记录在 commondir 文件中
+	if common, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+		repo.CommonDir = filepath.Clean(commonDir)
+	}
+	return repo, true
+}
+
+// StampFiles 返回决定分支和提交信息的文件，调用方可以根据它们的修改时间判断缓存是否失效
+func (r *GitRepo) StampFiles(branch string) []string {
+	files := []string{
+		filepath.Join(r.GitDir, "HEAD"),
+		filepath.Join(r.GitDir, "index"),
+		filepath.Join(r.CommonDir, "packed-refs"),
+		filepath.Join(r.CommonDir, "config"),
+	}
+	if branch != "" {
+		files = append(files, filepath.Join(r.CommonDir, "refs", "heads", branch))
+		if upstream := r.upstreamRef(branch); upstream != "" {
+			files = append(files, filepath.Join(r.CommonDir, upstream))
+		}
+	}
+	return files
+}
+
+// Head 读取 HEAD，返回分支名（分离 HEAD 时为空）和提交 ID
+func (r *GitRepo) Head() (string, string, error) {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, "HEAD"))
+	if err != nil {
+		return "", "", err
+	}
+	head := strings.TrimSpace(string(data))
+	ref, ok := strings.CutPrefix(head, "ref: ")
+	if !ok {
+		return "", head, nil
+	}
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+	sha, err := r.ResolveRef(ref)
+	if err != nil {
+		// 还没有任何提交的新仓库
+		return branch, "", nil
+	}
+	return branch, sha, nil
+}
+
+// ResolveRef 解析引用，依次查找松散引用文件和 packed-refs
+func (r *GitRepo) ResolveRef(ref string) (string, error) {
+	for depth := 0; depth < 5; depth++ {
+		data, err := os.ReadFile(filepath.Join(r.CommonDir, ref))
+		if err != nil {
+			return r.resolvePackedRef(ref)
+		}
+		value := strings.TrimSpace(string(data))
+		next, ok := strings.CutPrefix(value, "ref: ")
+		if !ok {
+			return value, nil
+		}
+		ref = next
+	}
+	return "", errors.New("too many levels of symbolic refs")
+}
+
+func (r *GitRepo) resolvePackedRef(ref string) (string, error) {
+	f, err := os.Open(filepath.Join(r.CommonDir, "packed-refs"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+			continue
+		}
+		if sha, name, ok := strings.Cut(line, " "); ok && name == ref {
+			return sha, nil
+		}
+	}
+	return "", errors.New("ref not found: " + ref)
+}
+
+// upstreamRef 从 config 中读取分支的上游，返回形如 refs/remotes/origin/main 的引用
+func (r *GitRepo) upstreamRef(branch string) string {
+	f, err := os.Open(filepath.Join(r.CommonDir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	section := `[branch "` + branch + `"]`
+	inSection := false
+	var remote, merge string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "remote":
+			remote = strings.TrimSpace(value)
+		case "merge":
+			merge = strings.TrimSpace(value)
+		}
+	}
+	if remote == "" || !strings.HasPrefix(merge, "refs/heads/") {
+		return ""
+	}
+	if remote == "." {
+		return merge
+	}
+	return "refs/remotes/" + remote + "/" + strings.TrimPrefix(merge, "refs/heads/")
+}
+
+// ErrHistoryTooLong 表示计算领先/落后提交数时遍历的提交超过了上限，结果未知
+var ErrHistoryTooLong = errors.New("too many commits to compare")
+
+// AheadBehind 计算本地分支相对上游领先和落后的提交数
+// 从两端同时按提交时间从新到旧遍历，直到剩下的提交都是两边共有的（已经到达合并基础），
+// 只统计不共有的提交；遍历超过 limit 个提交时返回 ErrHistoryTooLong，而不是给出错误的数字
+func (r *GitRepo) AheadBehind(branch string, head string, limit int) (int, int, error) {
+	upstream := r.upstreamRef(branch)
+	if upstream == "" || head == "" {
+		return 0, 0, nil
+	}
+	upstreamSHA, err := r.ResolveRef(upstream)
+	if err != nil || upstreamSHA == head {
+		return 0, 0, nil
+	}
+
+	store := newGitObjectStore(filepath.Join(r.CommonDir, "objects"))
+	defer store.close()
+	flags, err := store.paintDown(head, upstreamSHA, limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	ahead, behind := 0, 0
+	for _, flag := range flags {
+		switch flag {
+		case paintLeft:
+			ahead++
+		case paintRight:
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// paintDown 使用的标记：提交能从哪一端到达，两端都能到达的是共有的提交
+const (
+	paintLeft  = 1
+	paintRight = 2
+	paintBoth  = paintLeft | paintRight
+)
+
+// paintDown 从 left 和 right 开始按提交时间从新到旧遍历祖先，把每个提交能从哪一端到达的标记传给父提交，
+// 提交时间相同时先处理先加入队列的提交；队列中只剩两端共有的提交时停止，
+// 然后把共有的标记继续传给已经访问过的祖先（它们可能在共有的提交到达之前就被处理了）；返回访问过的提交的标记
+func (s *gitObjectStore) paintDown(left string, right string, limit int) (map[string]int, error) {
+	flags := map[string]int{left: paintLeft, right: paintRight}
+	commits := make(map[string]*gitCommit) // 已经读取的提交
+	processed := make(map[string]int)      // 上一次处理提交时它的标记，标记没有变化时不需要重复处理
+	queue := &commitQueue{}
+	push := func(sha string) error {
+		commit, ok := commits[sha]
+		if !ok {
+			var err error
+			if commit, err = s.readCommit(sha); err != nil {
+				return err
+			}
+			commits[sha] = commit
+		}
+		heap.Push(queue, queuedCommit{sha: sha, commit: commit, seq: queue.seq})
+		queue.seq++
+		return nil
+	}
+	for _, sha := range []string{left, right} {
+		if err := push(sha); err != nil {
+			return nil, err
+		}
+	}
+
+	visited := 0
+	for queue.Len() > 0 && queue.hasUnshared(flags) {
+		item := heap.Pop(queue).(queuedCommit)
+		flag := flags[item.sha]
+		if processed[item.sha] == flag {
+			continue
+		}
+		processed[item.sha] = flag
+		if visited++; visited > limit {
+			return nil, ErrHistoryTooLong
+		}
+		for _, parent := range item.commit.parents {
+			if flags[parent]|flag == flags[parent] {
+				continue
+			}
+			flags[parent] |= flag
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 共有的提交的祖先也是共有的
+	var shared []string
+	for sha, flag := range flags {
+		if flag == paintBoth {
+			shared = append(shared, sha)
+		}
+	}
+	for len(shared) > 0 {
+		sha := shared[len(shared)-1]
+		shared = shared[:len(shared)-1]
+		for _, parent := range commits[sha].parents {
+			if flag, ok := flags[parent]; ok && flag != paintBoth {
+				flags[parent] = paintBoth
+				shared = append(shared, parent)
+			}
+		}
+	}
+	return flags, nil
+}
+
+type queuedCommit struct {
+	sha    string
+	commit *gitCommit
+	seq    int // 加入队列的顺序
+}
+
+// commitQueue 按提交时间从新到旧排列，时间相同时按加入队列的顺序
+type commitQueue struct {
+	items []queuedCommit
+	seq   int // 下一个加入队列的提交的序号
+}
+
+func (q *commitQueue) Len() int { return len(q.items) }
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if a.commit.time != b.commit.time {
+		return a.commit.time > b.commit.time
+	}
+	return a.seq < b.seq
+}
+func (q *commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+func (q *commitQueue) Push(x any)    { q.items = append(q.items, x.(queuedCommit)) }
+func (q *commitQueue) Pop() any {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return item
+}
+
+// hasUnshared 判断队列中是否还有不是两端共有的提交
+func (q *commitQueue) hasUnshared(flags map[string]int) bool {
+	for _, item := range q.items {
+		if flags[item.sha] != paintBoth {
+			return true
+		}
+	}
+	return false
+}
+
+// GitIndexEntry 是 .git/index 中的一项
+type GitIndexEntry struct {
+	Path        string
+	SHA         string
+	Mode        uint32
+	Size        uint32
+	ModTime     time.Time
+	AssumeValid bool // 设置了 assume-unchanged 或 skip-worktree，不检查工作区
+	Stage       int  // 合并冲突时不为 0
+}
+
+// GitIndex 是解析后的 .git/index
+// 同一个 index 文件（修改时间相同）的检查结果可以重复使用，工作区文件的哈希缓存在其中
+type GitIndex struct {
+	Entries   []GitIndexEntry // 按路径排序
+	ModTime   time.Time       // index 文件的修改时间
+	cacheTree *gitCacheTree   // TREE 扩展，没有时为 nil
+	hashed    map[string]hashedFile
+}
+
+// hashedFile 记录计算过哈希的工作区文件，文件的大小和修改时间不变时不需要重新计算
+type hashedFile struct {
+	size    int64
+	modTime time.Time
+	sha     string
+}
+
+// gitCacheTree 是 index 的 TREE 扩展中的一个目录：entries 是目录下（含子目录）的 index 项数，
+// sha 是这些项组成的树对象，目录中的项被修改过时扩展中记为无效（entries 为 -1）
+type gitCacheTree struct {
+	entries  int
+	sha      string
+	children map[string]*gitCacheTree
+}
+
+// ReadIndex 解析 .git/index（支持 v2、v3、v4）及其中的 TREE 扩展
+func (r *GitRepo) ReadIndex() (*GitIndex, error) {
+	indexFile := filepath.Join(r.GitDir, "index")
+	info, err := os.Stat(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errors.New("invalid git index")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, errors.New("unsupported git index version " + strconv.Itoa(int(version)))
+	}
+	count := int(binary.BigEndian.Uint32(data[8:12]))
+
+	entries := make([]GitIndexEntry, 0, count)
+	pos := 12
+	prevPath := ""
+	for i := 0; i < count; i++ {
+		// 固定部分：ctime(8) mtime(8) dev ino mode uid gid size(各 4) sha(20) flags(2)
+		if pos+62 > len(data) {
+			return nil, errors.New("truncated git index")
+		}
+		entry := data[pos:]
+		mtimeSec := binary.BigEndian.Uint32(entry[8:12])
+		mtimeNsec := binary.BigEndian.Uint32(entry[12:16])
+		flags := binary.BigEndian.Uint16(entry[60:62])
+		e := GitIndexEntry{
+			Mode:        binary.BigEndian.Uint32(entry[24:28]),
+			Size:        binary.BigEndian.Uint32(entry[36:40]),
+			SHA:         hex.EncodeToString(entry[40:60]),
+			ModTime:     time.Unix(int64(mtimeSec), int64(mtimeNsec)),
+			AssumeValid: flags&0x8000 != 0,
+			Stage:       int(flags>>12) & 3,
+		}
+		headerLen := 62
+		if version >= 3 && flags&0x4000 != 0 {
+			// 扩展标志：skip-worktree 的文件不在工作区中
+			if pos+64 > len(data) {
+				return nil, errors.New("truncated git index")
+			}
+			extended := binary.BigEndian.Uint16(entry[62:64])
+			e.AssumeValid = e.AssumeValid || extended&0x4000 != 0
+			headerLen = 64
+		}
+
+		rest := data[pos+headerLen:]
+		if version == 4 {
+			// v4 的路径相对上一项做了前缀压缩：先是需要删除的字节数，再是以 NUL 结尾的后缀
+			strip, n := readIndexVarint(rest)
+			if n == 0 || strip > len(prevPath) {
+				return nil, errors.New("malformed git index")
+			}
+			suffix, _, ok := bytes.Cut(rest[n:], []byte{0})
+			if !ok {
+				return nil, errors.New("malformed git index")
+			}
+			e.Path = prevPath[:len(prevPath)-strip] + string(suffix)
+			pos += headerLen + n + len(suffix) + 1
+		} else {
+			name, _, ok := bytes.Cut(rest, []byte{0})
+			if !ok {
+				return nil, errors.New("malformed git index")
+			}
+			e.Path = string(name)
+			// 每一项用 1 到 8 个 NUL 填充到 8 字节的整数倍
+			pos += (headerLen + len(name) + 8) &^ 7
+		}
+		prevPath = e.Path
+		entries = append(entries, e)
+	}
+	index := &GitIndex{Entries: entries, ModTime: info.ModTime(), hashed: make(map[string]hashedFile)}
+
+	// 项之后是扩展，每个扩展是 4 字节签名、4 字节长度和内容，最后 20 字节是校验和
+	for pos+8 <= len(data)-20 {
+		signature := string(data[pos : pos+4])
+		size := int(binary.BigEndian.Uint32(data[pos+4 : pos+8]))
+		if pos+8+size > len(data)-20 {
+			break
+		}
+		if signature == "TREE" {
+			// 扩展损坏时不使用，退回到逐层比较
+			if tree, _, err := parseCacheTree(data[pos+8 : pos+8+size]); err == nil {
+				index.cacheTree = tree
+			}
+		}
+		pos += 8 + size
+	}
+	return index, nil
+}
+
+// parseCacheTree 解析 TREE 扩展中的一个目录及其子目录，返回剩余的数据
+// 每个目录的格式为 "path\0entries subtrees\n"，entries 不为 -1 时后面是 20 字节的树对象 ID
+func parseCacheTree(data []byte) (*gitCacheTree, []byte, error) {
+	_, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return nil, nil, errors.New("malformed cache tree")
+	}
+	line, rest, ok := bytes.Cut(rest, []byte{'\n'})
+	if !ok {
+		return nil, nil, errors.New("malformed cache tree")
+	}
+	countStr, subtreesStr, _ := strings.Cut(string(line), " ")
+	count, err1 := strconv.Atoi(countStr)
+	subtrees, err2 := strconv.Atoi(subtreesStr)
+	if err1 != nil || err2 != nil {
+		return nil, nil, errors.New("malformed cache tree")
+	}
+	tree := &gitCacheTree{entries: count, children: make(map[string]*gitCacheTree, subtrees)}
+	if count >= 0 {
+		if len(rest) < 20 {
+			return nil, nil, errors.New("malformed cache tree")
+		}
+		tree.sha = hex.EncodeToString(rest[:20])
+		rest = rest[20:]
+	}
+	for i := 0; i < subtrees; i++ {
+		name, _, _ := bytes.Cut(rest, []byte{0})
+		child, next, err := parseCacheTree(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		tree.children[string(name)] = child
+		rest = next
+	}
+	return tree, rest, nil
+}
+
+// readIndexVarint 读取 index v4 中使用的变长整数，返回值和消耗的字节数
+func readIndexVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	value := int(data[0] & 0x7f)
+	n := 1
+	for data[n-1]&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | int(data[n]&0x7f)
+		n++
+	}
+	return value, n
+}
+
+// HasUnstagedChanges 比较 index 中记录的大小和修改时间判断工作区是否被修改
+// 与 index 在同一时刻之后修改的文件（racy git）需要计算内容哈希才能确定；
+// 计算过的哈希缓存在 index 中，文件没有再变化时之后的检查直接使用
+func (r *GitRepo) HasUnstagedChanges(index *GitIndex) bool {
+	for _, e := range index.Entries {
+		if e.AssumeValid || e.Mode == 0o160000 {
+			// 子模块和标记为不检查的文件跳过
+			continue
+		}
+		if e.Stage != 0 {
+			// 存在未解决的冲突
+			return true
+		}
+		path := filepath.Join(r.Root, filepath.FromSlash(e.Path))
+		info, err := os.Lstat(path)
+		if err != nil {
+			return true
+		}
+		if uint32(info.Size()) != e.Size {
+			return true
+		}
+		if info.ModTime().Equal(e.ModTime) && e.ModTime.Before(index.ModTime) {
+			continue
+		}
+		// 修改时间不同（可能只是 touch）或无法通过时间判断时比较内容
+		if sha, err := index.hashFile(path, info); err != nil || sha != e.SHA {
+			return true
+		}
+	}
+	return false
+}
+
+// hashFile 返回工作区文件的 blob 对象 ID，大小和修改时间与上次计算时相同时使用缓存
+func (index *GitIndex) hashFile(path string, info os.FileInfo) (string, error) {
+	if cached, ok := index.hashed[path]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.sha, nil
+	}
+	sha, err := hashWorktreeFile(path, info)
+	if err != nil {
+		return "", err
+	}
+	index.hashed[path] = hashedFile{size: info.Size(), modTime: info.ModTime(), sha: sha}
+	return sha, nil
+}
+
+// hashWorktreeFile 按 Git 的方式计算文件的 blob 对象 ID，符号链接使用链接目标作为内容
+func hashWorktreeFile(path string, info os.FileInfo) (string, error) {
+	var content []byte
+	var err error
+	if info.Mode()&os.ModeSymlink != 0 {
+		var target string
+		target, err = os.Readlink(path)
+		content = []byte(target)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	h.Write([]byte("blob " + strconv.Itoa(len(content)) + "\x00"))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HasStagedChanges 比较 index 与 HEAD 提交的树，判断是否有已暂存但未提交的修改
+// 从根目录开始逐层比较：TREE 扩展中记录的目录树对象与 HEAD 中的相同时整个目录没有变化，不再展开
+func (r *GitRepo) HasStagedChanges(index *GitIndex, head string) (bool, error) {
+	if head == "" {
+		// 还没有提交时，index 中的任何文件都是已暂存的
+		return len(index.Entries) > 0, nil
+	}
+	for _, e := range index.Entries {
+		if e.Stage != 0 {
+			return true, nil
+		}
+	}
+	store := newGitObjectStore(filepath.Join(r.CommonDir, "objects"))
+	defer store.close()
+	commit, err := store.readCommit(head)
+	if err != nil {
+		return false, err
+	}
+	return index.treeChanged(store, commit.tree, "", index.cacheTree)
+}
+
+// treeChanged 判断 index 中 prefix 目录下的项与树对象 sha 是否不同，cache 是这个目录在 TREE 扩展中的记录
+func (index *GitIndex) treeChanged(store *gitObjectStore, sha string, prefix string, cache *gitCacheTree) (bool, error) {
+	if cache != nil && cache.entries >= 0 && cache.sha == sha {
+		return false, nil
+	}
+	items, err := store.readTree(sha)
+	if err != nil {
+		return false, err
+	}
+	matched := 0
+	for _, item := range items {
+		path := prefix + item.name
+		if item.mode == 0o40000 {
+			var child *gitCacheTree
+			if cache != nil {
+				child = cache.children[item.name]
+			}
+			changed, err := index.treeChanged(store, item.sha, path+"/", child)
+			if err != nil || changed {
+				return changed, err
+			}
+			matched += index.countPrefix(path + "/")
+			continue
+		}
+		e, ok := index.find(path)
+		if !ok || e.SHA != item.sha || e.Mode != item.mode {
+			return true, nil
+		}
+		matched++
+	}
+	// index 中有 HEAD 的这个目录里没有的文件
+	return matched != index.countPrefix(prefix), nil
+}
+
+// find 用二分查找返回路径对应的 index 项
+func (index *GitIndex) find(path string) (GitIndexEntry, bool) {
+	i := sort.Search(len(index.Entries), func(i int) bool { return index.Entries[i].Path >= path })
+	if i < len(index.Entries) && index.Entries[i].Path == path {
+		return index.Entries[i], true
+	}
+	return GitIndexEntry{}, false
+}
+
+// countPrefix 返回路径以 prefix 开头的 index 项数，这些项在排序后是连续的
+func (index *GitIndex) countPrefix(prefix string) int {
+	lo := sort.Search(len(index.Entries), func(i int) bool { return index.Entries[i].Path >= prefix })
+	hi := lo + sort.Search(len(index.Entries)-lo, func(i int) bool {
+		return !strings.HasPrefix(index.Entries[lo+i].Path, prefix)
+	})
+	return hi - lo
+}
@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitTestRepo 用 git fast-import 创建一个有 commits 个提交的线性历史，返回仓库
+func gitTestRepo(t *testing.T, commits int) *GitRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git(t, dir, "", "init", "-q", "-b", "main")
+	var stream strings.Builder
+	for i := 1; i <= commits; i++ {
+		msg := fmt.Sprintf("commit %d\n", i)
+		fmt.Fprintf(&stream, "commit refs/heads/main\ncommitter T <t@example.com> %d +0000\ndata %d\n%s", 1700000000+i, len(msg), msg)
+		fmt.Fprintf(&stream, "M 644 inline file.txt\ndata %d\n%s\n", len(msg), msg)
+	}
+	git(t, dir, stream.String(), "fast-import", "--quiet")
+	git(t, dir, "", "config", "branch.main.remote", "origin")
+	git(t, dir, "", "config", "branch.main.merge", "refs/heads/main")
+	repo, ok := FindGitRepo(dir)
+	if !ok {
+		t.Fatal("repository not found")
+	}
+	return repo
+}
+
+func git(t *testing.T, dir string, stdin string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// 历史比遍历上限长时，只要合并基础在上限之内，结果与 git rev-list --left-right --count 相同
+func TestAheadBehindLongHistory(t *testing.T) {
+	const limit = 2000
+	repo := gitTestRepo(t, limit+100)
+	git(t, repo.Root, "", "update-ref", "refs/remotes/origin/main", "main~1")
+	_, head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ahead, behind, err := repo.AheadBehind("main", head, limit)
+	if err != nil || ahead != 1 || behind != 0 {
+		t.Errorf("AheadBehind = %d, %d, %v; want 1, 0, nil", ahead, behind, err)
+	}
+
+	// 上游落后太多时结果未知
+	git(t, repo.Root, "", "update-ref", "refs/remotes/origin/main", "main~"+fmt.Sprint(limit+50))
+	if _, _, err := repo.AheadBehind("main", head, limit); !errors.Is(err, ErrHistoryTooLong) {
+		t.Errorf("AheadBehind error = %v; want ErrHistoryTooLong", err)
+	}
+}
+
+// 暂存区和工作区的检查结果与 git diff --cached / git diff 一致，包括 TREE 扩展有效和无效的情况
+func TestStagedAndUnstagedChanges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git(t, dir, "", "init", "-q", "-b", "main")
+	git(t, dir, "", "config", "user.email", "t@example.com")
+	git(t, dir, "", "config", "user.name", "T")
+	write := func(path string, content string) {
+		t.Helper()
+		full := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		write(fmt.Sprintf("src/pkg%d/file.go", i%4), strings.Repeat(fmt.Sprintf("line %d\n", i), 50))
+		write("README", strings.Repeat("readme\n", i+1))
+		git(t, dir, "", "add", "-A")
+		git(t, dir, "", "commit", "-qm", fmt.Sprint(i))
+	}
+	// 打包后树对象以增量形式保存
+	git(t, dir, "", "gc", "-q")
+
+	check := func(name string) {
+		t.Helper()
+		repo, _ := FindGitRepo(dir)
+		_, head, err := repo.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		index, err := repo.ReadIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		staged, err := repo.HasStagedChanges(index, head)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantStaged := exec.Command("git", "-C", dir, "diff", "--cached", "--quiet").Run() != nil
+		if staged != wantStaged {
+			t.Errorf("%s: staged = %v, want %v", name, staged, wantStaged)
+		}
+		wantUnstaged := exec.Command("git", "-C", dir, "diff", "--quiet").Run() != nil
+		if unstaged := repo.HasUnstagedChanges(index); unstaged != wantUnstaged {
+			t.Errorf("%s: unstaged = %v, want %v", name, unstaged, wantUnstaged)
+		}
+	}
+
+	check("clean")
+	write("src/pkg2/file.go", "changed\n")
+	check("modified")
+	git(t, dir, "", "add", "src/pkg2/file.go")
+	check("staged in subdirectory")
+	git(t, dir, "", "reset", "-q", "--hard")
+	check("reset")
+	write("src/new/dir/file.go", "new\n")
+	git(t, dir, "", "add", "-A")
+	check("new directory")
+	git(t, dir, "", "reset", "-q", "--hard")
+	git(t, dir, "", "rm", "-q", "src/pkg1/file.go")
+	check("deleted")
+}
+
+// 所有提交的时间相同（例如在同一秒内提交和推送）时，结果与 git rev-list --left-right --count 相同
+func TestAheadBehindSameTimestamp(t *testing.T) {
+	repo := gitTestRepo(t, 5)
+	commit := func(msg string) {
+		t.Helper()
+		cmd := exec.Command("git", "-c", "user.name=T", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", msg)
+		cmd.Dir = repo.Root
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_DATE=@1700000000 +0000", "GIT_AUTHOR_DATE=@1700000000 +0000")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git commit: %v\n%s", err, out)
+		}
+	}
+	// 基础提交也使用同一个时间
+	git(t, repo.Root, "", "checkout", "-q", "--orphan", "tmp")
+	git(t, repo.Root, "", "rm", "-rfq", "--cached", ".")
+	commit("base 1")
+	commit("base 2")
+	git(t, repo.Root, "", "branch", "-q", "-f", "main")
+	commit("upstream 1")
+	commit("upstream 2")
+	git(t, repo.Root, "", "update-ref", "refs/remotes/origin/main", "HEAD")
+	git(t, repo.Root, "", "checkout", "-q", "-f", "main")
+	commit("local")
+	want := git(t, repo.Root, "", "rev-list", "--left-right", "--count", "main...origin/main")
+
+	_, head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ahead, behind, err := repo.AheadBehind("main", head, 100)
+	if got := fmt.Sprintf("%d\t%d", ahead, behind); err != nil || got != want || got != "1\t2" {
+		t.Errorf("AheadBehind = %q, %v; want %q", got, err, want)
+	}
+}
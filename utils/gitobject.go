@@ -0,0 +1,438 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gitObjectStore 直接读取 .git/objects 中的对象，支持松散对象和 pack 文件（含增量对象）
+// 只实现提示符需要的部分：读取提交和树，不支持 alternates 和 SHA-256 仓库
+type gitObjectStore struct {
+	dir   string // objects 目录
+	packs []*gitPack
+}
+
+// pack 文件中的对象类型
+const (
+	packObjCommit   = 1
+	packObjTree     = 2
+	packObjBlob     = 3
+	packObjTag      = 4
+	packObjOfsDelta = 6
+	packObjRefDelta = 7
+)
+
+var packTypeNames = map[int]string{
+	packObjCommit: "commit",
+	packObjTree:   "tree",
+	packObjBlob:   "blob",
+	packObjTag:    "tag",
+}
+
+var errGitObjectNotFound = errors.New("git object not found")
+
+func newGitObjectStore(objectsDir string) *gitObjectStore {
+	store := &gitObjectStore{dir: objectsDir}
+	idxFiles, _ := filepath.Glob(filepath.Join(objectsDir, "pack", "*.idx"))
+	for _, idxFile := range idxFiles {
+		if pack, err := openGitPack(idxFile); err == nil {
+			store.packs = append(store.packs, pack)
+		}
+	}
+	return store
+}
+
+// close 关闭打开的 pack 文件
+func (s *gitObjectStore) close() {
+	for _, pack := range s.packs {
+		pack.data.Close()
+	}
+}
+
+// readObject 读取对象，返回类型（commit/tree/blob/tag）和内容
+func (s *gitObjectStore) readObject(sha string) (string, []byte, error) {
+	if len(sha) != 40 {
+		return "", nil, fmt.Errorf("invalid object id %q", sha)
+	}
+	// 先查找松散对象：objects/xx/yyyy...
+	if f, err := os.Open(filepath.Join(s.dir, sha[:2], sha[2:])); err == nil {
+		defer f.Close()
+		return readLooseObject(f)
+	}
+
+	raw, err := hex.DecodeString(sha)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, pack := range s.packs {
+		if offset, ok := pack.find(raw); ok {
+			return pack.readAt(s, offset)
+		}
+	}
+	return "", nil, errGitObjectNotFound
+}
+
+// readLooseObject 解压松散对象，格式为 "type size\0content"
+func readLooseObject(r io.Reader) (string, []byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+	header, content, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return "", nil, errors.New("malformed loose object")
+	}
+	objType, _, _ := strings.Cut(string(header), " ")
+	return objType, content, nil
+}
+
+// gitPack 表示一对 .idx/.pack 文件（只支持 v2 索引）
+type gitPack struct {
+	fanout  [256]uint32
+	shas    []byte // 按顺序排列的 20 字节对象 ID
+	offsets []byte // 每个对象 4 字节偏移
+	large   []byte // 超过 2GB 时使用的 8 字节偏移
+	data    *os.File
+	bases   *deltaBaseCache
+}
+
+// 增量对象基础对象缓存的容量：最多缓存的对象数和总字节数
+const (
+	deltaBaseCacheObjects = 256
+	deltaBaseCacheBytes   = 16 << 20
+)
+
+// deltaBaseCache 是按 pack 中的偏移缓存已还原的基础对象的 LRU，
+// 同一棵树或一段历史中的对象通常共用增量链上的基础对象，不需要每次从头还原
+type deltaBaseCache struct {
+	order *list.List              // 最近使用的在前面
+	items map[int64]*list.Element // 偏移对应的链表元素
+	bytes int
+}
+
+type deltaBase struct {
+	offset  int64
+	objType string
+	data    []byte
+}
+
+func newDeltaBaseCache() *deltaBaseCache {
+	return &deltaBaseCache{order: list.New(), items: make(map[int64]*list.Element)}
+}
+
+func (c *deltaBaseCache) get(offset int64) (*deltaBase, bool) {
+	elem, ok := c.items[offset]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*deltaBase), true
+}
+
+// add 加入一个基础对象，超出容量时淘汰最久没有使用的对象；太大的对象不缓存
+func (c *deltaBaseCache) add(base *deltaBase) {
+	if len(base.data) > deltaBaseCacheBytes/4 {
+		return
+	}
+	if _, ok := c.items[base.offset]; ok {
+		return
+	}
+	c.items[base.offset] = c.order.PushFront(base)
+	c.bytes += len(base.data)
+	for c.order.Len() > deltaBaseCacheObjects || c.bytes > deltaBaseCacheBytes {
+		oldest := c.order.Back()
+		evicted := c.order.Remove(oldest).(*deltaBase)
+		delete(c.items, evicted.offset)
+		c.bytes -= len(evicted.data)
+	}
+}
+
+func openGitPack(idxFile string) (*gitPack, error) {
+	idx, err := os.ReadFile(idxFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, errors.New("unsupported pack index version")
+	}
+	pack := &gitPack{bases: newDeltaBaseCache()}
+	for i := 0; i < 256; i++ {
+		pack.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	count := int(pack.fanout[255])
+	shaStart := 8 + 256*4
+	offsetStart := shaStart + count*20 + count*4 // 跳过 CRC32 表
+	if len(idx) < offsetStart+count*4 {
+		return nil, errors.New("truncated pack index")
+	}
+	pack.shas = idx[shaStart : shaStart+count*20]
+	pack.offsets = idx[offsetStart : offsetStart+count*4]
+	pack.large = idx[offsetStart+count*4:]
+
+	pack.data, err = os.Open(strings.TrimSuffix(idxFile, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
+
+// find 通过 fanout 表和二分查找定位对象在 pack 文件中的偏移
+func (p *gitPack) find(sha []byte) (int64, bool) {
+	lo := 0
+	if sha[0] > 0 {
+		lo = int(p.fanout[sha[0]-1])
+	}
+	hi := int(p.fanout[sha[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.shas[(lo+i)*20:(lo+i+1)*20], sha) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.shas[i*20:(i+1)*20], sha) {
+		return 0, false
+	}
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 != 0 {
+		pos := int(offset&0x7fffffff) * 8
+		if pos+8 > len(p.large) {
+			return 0, false
+		}
+		return int64(binary.BigEndian.Uint64(p.large[pos:])), true
+	}
+	return int64(offset), true
+}
+
+// readAt 读取 pack 中指定偏移处的对象，增量对象会递归读取基础对象后还原
+func (p *gitPack) readAt(store *gitObjectStore, offset int64) (string, []byte, error) {
+	r := io.NewSectionReader(p.data, offset, 1<<62)
+	var buf [1]byte
+
+	// 对象头：3 位类型 + 可变长度的大小
+	if _, err := r.Read(buf[:]); err != nil {
+		return "", nil, err
+	}
+	objType := int(buf[0]>>4) & 7
+	for buf[0]&0x80 != 0 {
+		if _, err := r.Read(buf[:]); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var baseType string
+	var base []byte
+	switch objType {
+	case packObjOfsDelta:
+		// 基础对象位于当前对象之前的相对偏移处
+		if _, err := r.Read(buf[:]); err != nil {
+			return "", nil, err
+		}
+		rel := int64(buf[0] & 0x7f)
+		for buf[0]&0x80 != 0 {
+			if _, err := r.Read(buf[:]); err != nil {
+				return "", nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(buf[0]&0x7f)
+		}
+		var err error
+		baseType, base, err = p.readBase(store, offset-rel)
+		if err != nil {
+			return "", nil, err
+		}
+	case packObjRefDelta:
+		// 基础对象通过对象 ID 引用
+		var ref [20]byte
+		if _, err := io.ReadFull(r, ref[:]); err != nil {
+			return "", nil, err
+		}
+		var err error
+		if baseOffset, ok := p.find(ref[:]); ok {
+			baseType, base, err = p.readBase(store, baseOffset)
+		} else {
+			baseType, base, err = store.readObject(hex.EncodeToString(ref[:]))
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if base != nil {
+		data, err = applyGitDelta(base, data)
+		return baseType, data, err
+	}
+	name, ok := packTypeNames[objType]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown pack object type %d", objType)
+	}
+	return name, data, nil
+}
+
+// readBase 读取增量对象的基础对象，优先使用缓存
+func (p *gitPack) readBase(store *gitObjectStore, offset int64) (string, []byte, error) {
+	if base, ok := p.bases.get(offset); ok {
+		return base.objType, base.data, nil
+	}
+	objType, data, err := p.readAt(store, offset)
+	if err != nil {
+		return "", nil, err
+	}
+	p.bases.add(&deltaBase{offset: offset, objType: objType, data: data})
+	return objType, data, nil
+}
+
+// applyGitDelta 将增量指令应用到基础对象上
+func applyGitDelta(base []byte, delta []byte) ([]byte, error) {
+	readSize := func() int {
+		size, shift := 0, 0
+		for len(delta) > 0 {
+			b := delta[0]
+			delta = delta[1:]
+			size |= int(b&0x7f) << shift
+			shift += 7
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		return size
+	}
+	if readSize() != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+	result := make([]byte, 0, readSize())
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		if op&0x80 == 0 {
+			// 插入指令：后面 op 个字节是新数据
+			n := int(op)
+			if n == 0 || n > len(delta) {
+				return nil, errors.New("malformed delta")
+			}
+			result = append(result, delta[:n]...)
+			delta = delta[n:]
+			continue
+		}
+		// 复制指令：从基础对象复制 offset/size 指定的片段
+		var offset, size int
+		for i := 0; i < 4; i++ {
+			if op&(1<<i) != 0 {
+				if len(delta) == 0 {
+					return nil, errors.New("malformed delta")
+				}
+				offset |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if op&(1<<(4+i)) != 0 {
+				if len(delta) == 0 {
+					return nil, errors.New("malformed delta")
+				}
+				size |= int(delta[0]) << (8 * i)
+				delta = delta[1:]
+			}
+		}
+		if size == 0 {
+			size = 0x10000
+		}
+		if offset+size > len(base) {
+			return nil, errors.New("malformed delta")
+		}
+		result = append(result, base[offset:offset+size]...)
+	}
+	return result, nil
+}
+
+// gitCommit 只保留遍历历史需要的字段
+type gitCommit struct {
+	tree    string
+	parents []string
+	time    int64 // 提交时间（committer 的时间戳）
+}
+
+func (s *gitObjectStore) readCommit(sha string) (*gitCommit, error) {
+	objType, data, err := s.readObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != "commit" {
+		return nil, fmt.Errorf("%s is a %s, not a commit", sha, objType)
+	}
+	commit := &gitCommit{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			break // 头部结束，后面是提交信息
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			commit.tree = value
+		case "parent":
+			commit.parents = append(commit.parents, value)
+		case "committer":
+			// 格式为 "Name <email> timestamp timezone"
+			if fields := strings.Fields(value); len(fields) >= 2 {
+				commit.time, _ = strconv.ParseInt(fields[len(fields)-2], 10, 64)
+			}
+		}
+	}
+	return commit, nil
+}
+
+// gitTreeEntry 是树对象中的一项，mode 为 040000 时是子目录
+type gitTreeEntry struct {
+	name string
+	mode uint32
+	sha  string
+}
+
+// readTree 读取树对象的一层，不展开子目录
+func (s *gitObjectStore) readTree(sha string) ([]gitTreeEntry, error) {
+	objType, data, err := s.readObject(sha)
+	if err != nil {
+		return nil, err
+	}
+	if objType != "tree" {
+		return nil, fmt.Errorf("%s is a %s, not a tree", sha, objType)
+	}
+	var entries []gitTreeEntry
+	// 每一项的格式为 "mode name\0" + 20 字节对象 ID
+	for len(data) > 0 {
+		header, rest, ok := bytes.Cut(data, []byte{0})
+		if !ok || len(rest) < 20 {
+			return nil, errors.New("malformed tree object")
+		}
+		modeStr, name, _ := strings.Cut(string(header), " ")
+		mode, err := strconv.ParseUint(modeStr, 8, 32)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, gitTreeEntry{name: name, mode: uint32(mode), sha: hex.EncodeToString(rest[:20])})
+		data = rest[20:]
+	}
+	return entries, nil
+}
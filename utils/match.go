@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MatchMode 是补全时候选项的匹配方式
+type MatchMode int
+
+const (
+	MatchPrefix    MatchMode = iota // 候选项以输入开头
+	MatchSubstring                  // 候选项包含输入
+	MatchFuzzy                      // 输入的字符按顺序出现在候选项中（与 fzf 类似），按匹配质量打分
+)
+
+// 模糊匹配的得分：每个匹配的字符得分，匹配在单词开头、分隔符或驼峰之后以及连续匹配时加分，
+// 跳过的字符扣分
+const (
+	scoreMatch        = 16
+	bonusFirstChar    = 16
+	bonusBoundary     = 8
+	bonusConsecutive  = 8
+	penaltyGapStart   = 3
+	penaltyGapExtends = 1
+)
+
+// Match 判断 candidate 是否与 pattern 匹配，返回的得分越大匹配越好
+// ignoreCase 为 true 时不区分大小写
+func Match(mode MatchMode, pattern string, candidate string, ignoreCase bool) (int, bool) {
+	if mode == MatchFuzzy {
+		// 驼峰边界需要原来的大小写，逐个字符比较时再忽略大小写
+		return fuzzyScore([]rune(pattern), []rune(candidate), ignoreCase)
+	}
+	if ignoreCase {
+		pattern, candidate = strings.ToLower(pattern), strings.ToLower(candidate)
+	}
+	switch mode {
+	case MatchSubstring:
+		// 越靠前的匹配得分越高，在开头时与前缀匹配相同
+		i := strings.Index(candidate, pattern)
+		return -i, i != -1
+	default:
+		return 0, strings.HasPrefix(candidate, pattern)
+	}
+}
+
+// fuzzyScore 用动态规划计算 pattern 作为子序列在 candidate 中的最高得分
+// best[j] 是 pattern 的前 i+1 个字符中最后一个匹配在 candidate[j] 时的最高得分
+func fuzzyScore(pattern []rune, candidate []rune, ignoreCase bool) (int, bool) {
+	if len(pattern) == 0 {
+		return 0, true
+	}
+	if len(pattern) > len(candidate) {
+		return 0, false
+	}
+	const none = -1 << 30
+	prev := make([]int, len(candidate))
+	best := make([]int, len(candidate))
+	for i, p := range pattern {
+		for j, c := range candidate {
+			best[j] = none
+			if c != p && !(ignoreCase && unicode.ToLower(c) == unicode.ToLower(p)) {
+				continue
+			}
+			bonus := charBonus(candidate, j)
+			if i == 0 {
+				best[j] = scoreMatch + bonus - gapPenalty(j)
+				continue
+			}
+			for k := 0; k < j; k++ {
+				if prev[k] == none {
+					continue
+				}
+				score := prev[k] + scoreMatch
+				if k == j-1 {
+					score += max(bonus, bonusConsecutive)
+				} else {
+					score += bonus - gapPenalty(j-k-1)
+				}
+				best[j] = max(best[j], score)
+			}
+		}
+		prev, best = best, prev
+	}
+	result := none
+	for _, score := range prev {
+		result = max(result, score)
+	}
+	return result, result != none
+}
+
+// charBonus 是匹配在 candidate[j] 时的加分：单词开头、分隔符之后或小写到大写的驼峰边界
+func charBonus(candidate []rune, j int) int {
+	if j == 0 {
+		return bonusFirstChar
+	}
+	prev, ch := candidate[j-1], candidate[j]
+	if strings.ContainsRune("-_./ ", prev) || (unicode.IsLower(prev) && unicode.IsUpper(ch)) {
+		return bonusBoundary
+	}
+	return 0
+}
+
+func gapPenalty(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return penaltyGapStart + (n-1)*penaltyGapExtends
+}
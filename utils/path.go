@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// 查找可执行文件失败的原因
+// 找不到命令时 Shell 的退出状态为 127，找到了但无法执行时为 126
+var (
+	ErrNotFound   = errors.New("command not found")         // PATH 中没有这个命令
+	ErrNoSuchFile = errors.New("No such file or directory") // 带路径的命令不存在
+	ErrPermission = errors.New("Permission denied")         // 文件没有执行权限
+	ErrIsDir      = errors.New("Is a directory")            // 路径是一个目录
+)
+
+// windowsExecutableExts 是 Windows 上可以省略的可执行文件扩展名
+var windowsExecutableExts = []string{".exe", ".bat", ".cmd", ".com"}
+
+// executableCache 缓存在 PATH 中找到的可执行文件
+// key 由 PATH（含相对目录时还包括工作目录）组成，key 变化或调用 ResetExecutableCache 时清空
+var executableCache struct {
+	sync.Mutex
+	key   string
+	paths map[string]string
+}
+
+// ResetExecutableCache 清空可执行文件的缓存，例如 hash -r
+func ResetExecutableCache() {
+	executableCache.Lock()
+	defer executableCache.Unlock()
+	executableCache.paths = nil
+}
+
+// ForgetExecutable 从缓存中删除一个命令，下一次查找时重新搜索 PATH，例如 hash name 和 hash -d name
+func ForgetExecutable(command string) {
+	executableCache.Lock()
+	defer executableCache.Unlock()
+	delete(executableCache.paths, command)
+}
+
+// 查找可执行文件的函数
+// 含有路径分隔符的命令（如 ./build.sh、/usr/bin/env）直接检查该文件，不搜索 PATH
+// PATH 中的相对目录（如 "." 或 "bin"）相对于 workDir 解析，而不是进程的工作目录
+// PATH 中只有没有执行权限的同名文件时返回 ErrPermission，否则返回 ErrNotFound
+func FindExecutable(command string, pathEnv string, workDir string) (string, error) {
+	if hasPathSeparator(command) {
+		path := command
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workDir, path)
+		}
+		return path, CheckExecutable(path)
+	}
+
+	executableCache.Lock()
+	defer executableCache.Unlock()
+	key := pathEnv
+	if hasRelativeDir(pathEnv) {
+		key += "\x00" + workDir
+	}
+	if key != executableCache.key || executableCache.paths == nil {
+		executableCache.key = key
+		executableCache.paths = make(map[string]string)
+	}
+	// 缓存的文件可能已被删除，只需要检查这一个文件
+	if path, ok := executableCache.paths[command]; ok {
+		if CheckExecutable(path) == nil {
+			return path, nil
+		}
+		delete(executableCache.paths, command)
+	}
+
+	err := ErrNotFound
+	for _, dir := range PathDirs(pathEnv, workDir) {
+		for _, candidate := range executableCandidates(filepath.Join(dir, command)) {
+			switch CheckExecutable(candidate) {
+			case nil:
+				executableCache.paths[command] = candidate
+				return candidate, nil
+			case ErrPermission:
+				err = ErrPermission
+			}
+		}
+	}
+	return "", err
+}
+
+// SearchPath 按顺序在 pathEnv 的各个目录中查找可执行文件，返回所有匹配的完整路径（不使用缓存）
+func SearchPath(command string, pathEnv string, workDir string) []string {
+	if hasPathSeparator(command) {
+		if path, err := FindExecutable(command, pathEnv, workDir); err == nil {
+			return []string{path}
+		}
+		return nil
+	}
+	var paths []string
+	for _, dir := range PathDirs(pathEnv, workDir) {
+		for _, candidate := range executableCandidates(filepath.Join(dir, command)) {
+			if CheckExecutable(candidate) == nil {
+				paths = append(paths, candidate)
+			}
+		}
+	}
+	return paths
+}
+
+// CheckExecutable 检查路径是否是可以执行的文件
+func CheckExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ErrNoSuchFile
+	}
+	if info.IsDir() {
+		return ErrIsDir
+	}
+	if !IsExecutable(info) {
+		return ErrPermission
+	}
+	return nil
+}
+
+// IsExecutable 判断文件是否可执行：Unix 上检查执行权限位，Windows 上检查文件扩展名
+func IsExecutable(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(info.Name()))
+		for _, executableExt := range windowsExecutableExts {
+			if ext == executableExt {
+				return true
+			}
+		}
+		return false
+	}
+	return info.Mode()&0111 != 0
+}
+
+// PathDirs 拆分 PATH（Unix 上以 : 分隔，Windows 上以 ; 分隔），相对目录相对于 workDir 解析
+// 按 POSIX 的规定，空的目录（如 "/bin::/usr/bin" 中间或开头、结尾的空项）表示当前目录
+func PathDirs(pathEnv string, workDir string) []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			dir = "."
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// executableCandidates 返回 PATH 目录中可能对应命令的文件，Windows 上可以省略扩展名
+func executableCandidates(path string) []string {
+	if runtime.GOOS != "windows" || filepath.Ext(path) != "" {
+		return []string{path}
+	}
+	candidates := make([]string, len(windowsExecutableExts))
+	for i, ext := range windowsExecutableExts {
+		candidates[i] = path + ext
+	}
+	return candidates
+}
+
+func hasPathSeparator(command string) bool {
+	return strings.ContainsRune(command, '/') || strings.ContainsRune(command, filepath.Separator)
+}
+
+func hasRelativeDir(pathEnv string) bool {
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" || !filepath.IsAbs(dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// PATH 中空的目录表示当前目录，相对目录相对于 workDir 解析
+func TestPathDirs(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"/bin:/usr/bin", []string{"/bin", "/usr/bin"}},
+		{"/bin::/usr/bin", []string{"/bin", "/work", "/usr/bin"}},
+		{":/bin", []string{"/work", "/bin"}},
+		{"/bin:", []string{"/bin", "/work"}},
+		{"bin:.", []string{"/work/bin", "/work"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := PathDirs(tt.path, "/work"); !slices.Equal(got, tt.want) {
+			t.Errorf("PathDirs(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+// PATH 结尾的空目录在工作目录中查找命令，切换工作目录后不使用缓存的结果
+func TestFindExecutableEmptyPathEntry(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	for _, dir := range []string{dirA, dirB} {
+		if err := os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pathEnv := "/nonexistent:"
+	for _, dir := range []string{dirA, dirB} {
+		got, err := FindExecutable("tool", pathEnv, dir)
+		if want := filepath.Join(dir, "tool"); err != nil || got != want {
+			t.Errorf("FindExecutable in %s = %q, %v; want %q", dir, got, err, want)
+		}
+	}
+}
+
+// ForgetExecutable 之后重新搜索 PATH，找到排在前面的新程序
+func TestForgetExecutable(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(second, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pathEnv := first + string(os.PathListSeparator) + second
+	if got, _ := FindExecutable("tool", pathEnv, "/"); got != filepath.Join(second, "tool") {
+		t.Fatalf("got %q", got)
+	}
+	if err := os.WriteFile(filepath.Join(first, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ForgetExecutable("tool")
+	if got, _ := FindExecutable("tool", pathEnv, "/"); got != filepath.Join(first, "tool") {
+		t.Errorf("after ForgetExecutable got %q, want %q", got, filepath.Join(first, "tool"))
+	}
+}
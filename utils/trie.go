@@ -1,105 +1,350 @@
 package utils
 
 import (
-	"os"
-	"strings"
+	"container/heap"
+	"sort"
+	"sync"
 )
 
+// Entry 是 Trie 中一个单词附带的信息
+type Entry struct {
+	Word   string
+	Kind   string // 单词的类别，例如命令名的 builtin、command
+	Source string // 单词的来源，例如外部命令的完整路径
+	Uses   int    // 使用次数，TopK 按它排序
+}
+
+// Trie 是按字符组织的前缀树，每个节点的子节点按字符排序，遍历结果按字母顺序排列
+// 每个单词在结束的节点上保存一个 Entry，收集单词时直接取 Entry.Word，不需要沿路拼接字符串
+// Trie 可以被多个 goroutine 同时读写：查询持有读锁，修改持有写锁
 type Trie struct {
-	children map[rune]*Trie
-	isEnd    bool
+	mu   sync.RWMutex
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[rune]*trieNode
+	keys     []rune // 子节点的字符，按从小到大排列
+	entry    *Entry // 不为 nil 时表示有单词在这个节点结束
+	maxUses  int    // 子树中最大的使用次数，TopK 用它跳过不可能进入结果的子树
 }
 
 func Constructor() *Trie {
-	return &Trie{
-		children: make(map[rune]*Trie),
+	return &Trie{root: newTrieNode()}
+}
+
+func newTrieNode() *trieNode {
+	return &trieNode{
+		children: make(map[rune]*trieNode),
 	}
 }
 
+// Insert 插入单词，单词已存在时保留原来的信息
 func (t *Trie) Insert(word string) {
-	node := t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	node := t.root.insertPath(word)
+	if node.entry == nil {
+		node.entry = &Entry{Word: word}
+	}
+}
+
+// InsertEntry 插入单词及其信息，单词已存在时替换原来的信息
+func (t *Trie) InsertEntry(entry Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	node := t.root.insertPath(entry.Word)
+	node.entry = &entry
+	t.root.updateMaxUses(entry.Word)
+}
+
+// insertPath 创建单词经过的节点，返回最后一个节点
+func (n *trieNode) insertPath(word string) *trieNode {
+	node := n
 	for _, ch := range word {
-		if node.children == nil {
-			node.children = make(map[rune]*Trie)
+		child := node.children[ch]
+		if child == nil {
+			child = newTrieNode()
+			node.children[ch] = child
+			i := sort.Search(len(node.keys), func(i int) bool { return node.keys[i] >= ch })
+			node.keys = append(node.keys, 0)
+			copy(node.keys[i+1:], node.keys[i:])
+			node.keys[i] = ch
+		}
+		node = child
+	}
+	return node
+}
+
+// Get 返回单词的信息
+func (t *Trie) Get(word string) (Entry, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	node := t.root.find(word)
+	if node == nil || node.entry == nil {
+		return Entry{}, false
+	}
+	return *node.entry, true
+}
+
+// IncrementUses 把单词的使用次数加一，单词不存在时返回 false
+func (t *Trie) IncrementUses(word string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	node := t.root.find(word)
+	if node == nil || node.entry == nil {
+		return false
+	}
+	node.entry.Uses++
+	t.root.updateMaxUses(word)
+	return true
+}
+
+// Delete 删除单词，并删除不再通向任何单词的节点；单词不存在时返回 false
+func (t *Trie) Delete(word string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	path := []*trieNode{t.root}
+	chars := []rune(word)
+	for _, ch := range chars {
+		child := path[len(path)-1].children[ch]
+		if child == nil {
+			return false
 		}
-		if node.children[ch] == nil {
-			node.children[ch] = Constructor()
+		path = append(path, child)
+	}
+	node := path[len(path)-1]
+	if node.entry == nil {
+		return false
+	}
+	node.entry = nil
+	// 从单词末尾向上删除空节点，并重新计算路径上的 maxUses
+	for i := len(chars) - 1; i >= 0; i-- {
+		parent, child := path[i], path[i+1]
+		if child.entry == nil && len(child.keys) == 0 {
+			parent.removeChild(chars[i])
 		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].recomputeMaxUses()
+	}
+	return true
+}
+
+func (n *trieNode) removeChild(ch rune) {
+	delete(n.children, ch)
+	i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= ch })
+	if i < len(n.keys) && n.keys[i] == ch {
+		n.keys = append(n.keys[:i], n.keys[i+1:]...)
+	}
+}
+
+// updateMaxUses 在单词的使用次数改变后更新路径上各节点的 maxUses
+func (n *trieNode) updateMaxUses(word string) {
+	node := n
+	path := []*trieNode{n}
+	for _, ch := range word {
 		node = node.children[ch]
+		path = append(path, node)
+	}
+	// 使用次数可能被 InsertEntry 减小，所以从下向上重新计算
+	for i := len(path) - 1; i >= 0; i-- {
+		path[i].recomputeMaxUses()
 	}
-	node.isEnd = true
 }
 
-func (t *Trie) SearchWithPrefix(prefix string) *Trie {
-	node := t
+func (n *trieNode) recomputeMaxUses() {
+	n.maxUses = 0
+	if n.entry != nil {
+		n.maxUses = n.entry.Uses
+	}
+	for _, child := range n.children {
+		n.maxUses = max(n.maxUses, child.maxUses)
+	}
+}
+
+// find 返回 prefix 的最后一个字符对应的节点，不存在时返回 nil
+func (n *trieNode) find(prefix string) *trieNode {
+	node := n
 	for _, ch := range prefix {
-		if node.children == nil || node.children[ch] == nil {
+		node = node.children[ch]
+		if node == nil {
 			return nil
 		}
-		node = node.children[ch]
 	}
 	return node
 }
 
 func (t *Trie) Search(word string) bool {
-	node := t.SearchWithPrefix(word)
-	return node != nil && node.isEnd
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	node := t.root.find(word)
+	return node != nil && node.entry != nil
 }
 
 func (t *Trie) StartsWith(prefix string) bool {
-	return t.SearchWithPrefix(prefix) != nil
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.root.find(prefix) != nil
 }
 
-func (t *Trie) FindCompletions(prefix string) []string {
-	node := t.SearchWithPrefix(prefix)
-	if node == nil {
-		return nil
-	}
+// FindCompletions 按字母顺序返回以 prefix 开头的单词，limit 大于 0 时最多返回 limit 个
+func (t *Trie) FindCompletions(prefix string, limit int) []string {
 	var completions []string
-	t.collectWords(node, prefix, &completions)
+	for _, entry := range t.FindEntries(prefix, limit) {
+		completions = append(completions, entry.Word)
+	}
 	return completions
 }
 
-// collectWords 收集从给定节点开始的所有完整单词
-func (t *Trie) collectWords(node *Trie, prefix string, completions *[]string) {
+// FindEntries 按字母顺序返回以 prefix 开头的单词的信息，limit 大于 0 时最多返回 limit 个
+func (t *Trie) FindEntries(prefix string, limit int) []Entry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	node := t.root.find(prefix)
 	if node == nil {
-		return
+		return nil
 	}
+	var entries []Entry
+	node.collectEntries(limit, &entries)
+	return entries
+}
 
-	// 如果当前节点是完整单词，添加到结果中
-	// 注意：即使当前节点是完整单词，也要继续查找子节点
-	// 因为可能存在一个单词是另一个单词的前缀（如 xyz_fox 和 xyz_fox_rat）
-	if node.isEnd {
-		*completions = append(*completions, prefix)
+// collectEntries 按字母顺序收集从给定节点开始的所有单词，达到 limit 时停止
+// 注意：即使当前节点是完整单词，也要继续查找子节点
+// 因为可能存在一个单词是另一个单词的前缀（如 xyz_fox 和 xyz_fox_rat）
+func (n *trieNode) collectEntries(limit int, entries *[]Entry) bool {
+	if n.entry != nil {
+		*entries = append(*entries, *n.entry)
+		if limit > 0 && len(*entries) >= limit {
+			return false
+		}
 	}
-
-	// 遍历所有子节点（使用 map 后需要遍历 map）
-	if node.children != nil {
-		for ch, child := range node.children {
-			if child != nil {
-				t.collectWords(child, prefix+string(ch), completions)
-			}
+	for _, ch := range n.keys {
+		if !n.children[ch].collectEntries(limit, entries) {
+			return false
 		}
 	}
+	return true
 }
 
-// 查找可执行文件的函数
-func FindExecutable(command string) (string, bool) {
-	pathEnv := os.Getenv("PATH")
-	dirs := strings.Split(pathEnv, ":")
-	for _, dir := range dirs {
-		if dir == "" {
+// topItem 是 TopK 搜索中的一项：尚未展开的子树或已经确定的单词
+// 子树的 uses 是其中最大的使用次数，word 是子树的前缀，二者都不大于子树中任何单词的排序位置
+type topItem struct {
+	node  *trieNode
+	entry *Entry
+	uses  int
+	word  string
+}
+
+// topQueue 按使用次数从大到小、再按字母顺序排列
+type topQueue []topItem
+
+func (q topQueue) Len() int { return len(q) }
+func (q topQueue) Less(i, j int) bool {
+	if q[i].uses != q[j].uses {
+		return q[i].uses > q[j].uses
+	}
+	if q[i].word != q[j].word {
+		return q[i].word < q[j].word
+	}
+	// 前缀相同时先展开子树，子树中的单词不会排在它的前缀之前
+	return q[i].entry == nil && q[j].entry != nil
+}
+func (q topQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *topQueue) Push(x any)   { *q = append(*q, x.(topItem)) }
+func (q *topQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// TopK 返回以 prefix 开头、使用次数最多的 k 个单词，次数相同时按字母顺序排列
+// 按 maxUses 优先展开子树，只访问可能进入结果的节点，不需要遍历所有单词
+func (t *Trie) TopK(prefix string, k int) []Entry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	node := t.root.find(prefix)
+	if node == nil || k <= 0 {
+		return nil
+	}
+	queue := &topQueue{{node: node, uses: node.maxUses, word: prefix}}
+	var result []Entry
+	for queue.Len() > 0 && len(result) < k {
+		item := heap.Pop(queue).(topItem)
+		if item.entry != nil {
+			result = append(result, *item.entry)
 			continue
 		}
-		fullPath := dir + "/" + command
-		fileInfo, err := os.Stat(fullPath)
-		if err != nil {
-			continue
+		if item.node.entry != nil {
+			heap.Push(queue, topItem{entry: item.node.entry, uses: item.node.entry.Uses, word: item.word})
 		}
-		// 检查文件是否是常规文件并且有执行权限
-		if fileInfo.Mode().IsRegular() && fileInfo.Mode()&0111 != 0 {
-			return fullPath, true
+		for _, ch := range item.node.keys {
+			child := item.node.children[ch]
+			heap.Push(queue, topItem{node: child, uses: child.maxUses, word: item.word + string(ch)})
 		}
 	}
-	return "", false
+	return result
+}
+
+// similarWord 是 FindSimilar 找到的单词及其编辑距离
+type similarWord struct {
+	word     string
+	distance int
+}
+
+// FindSimilar 返回与 word 的编辑距离不超过 maxDistance 的单词，按距离从小到大、再按字母顺序排列
+// 编辑距离包括插入、删除、替换和相邻字符交换（如 gti 和 git 的距离为 1）
+// 沿着 Trie 逐层计算距离矩阵的一行，某一行的最小值超过 maxDistance 时不再继续向下查找
+func (t *Trie) FindSimilar(word string, maxDistance int) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	target := []rune(word)
+	row := make([]int, len(target)+1)
+	for i := range row {
+		row[i] = i
+	}
+	var matches []similarWord
+	for _, ch := range t.root.keys {
+		t.root.children[ch].searchSimilar(ch, 0, target, row, nil, maxDistance, &matches)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+	words := make([]string, len(matches))
+	for i, match := range matches {
+		words[i] = match.word
+	}
+	return words
+}
+
+// searchSimilar 计算以 ch 结尾的前缀与目标单词的距离矩阵的一行
+// prevRow、prevPrevRow 是上一个和上上一个字符对应的行，用于计算相邻字符交换
+// 子节点按字母顺序访问，找到的单词本身就按字母顺序排列
+func (n *trieNode) searchSimilar(ch rune, prevCh rune, target []rune, prevRow []int, prevPrevRow []int, maxDistance int, matches *[]similarWord) {
+	row := make([]int, len(prevRow))
+	row[0] = prevRow[0] + 1
+	minDistance := row[0]
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if target[i-1] == ch {
+			cost = 0
+		}
+		row[i] = min(row[i-1]+1, prevRow[i]+1, prevRow[i-1]+cost)
+		if prevPrevRow != nil && i > 1 && target[i-1] == prevCh && target[i-2] == ch {
+			row[i] = min(row[i], prevPrevRow[i-2]+1)
+		}
+		minDistance = min(minDistance, row[i])
+	}
+
+	if n.entry != nil && row[len(row)-1] <= maxDistance {
+		*matches = append(*matches, similarWord{word: n.entry.Word, distance: row[len(row)-1]})
+	}
+	if minDistance > maxDistance {
+		return
+	}
+	for _, next := range n.keys {
+		n.children[next].searchSimilar(next, ch, target, row, prevRow, maxDistance, matches)
+	}
 }